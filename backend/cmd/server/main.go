@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 
 	"cal-enderBE/internal/handlers"
+	"cal-enderBE/internal/reflow"
+	"cal-enderBE/internal/secretbox"
 	"cal-enderBE/internal/supabase"
 
 	"github.com/go-chi/chi/v5"
@@ -21,7 +24,33 @@ func main() {
 	}
 
 	client := supabase.NewClient(supabaseURL, serviceKey, anonKey)
-	app := &handlers.App{Supabase: client}
+	app := &handlers.App{
+		Supabase:          client,
+		GoogleCalendarURL: os.Getenv("GOOGLE_CALENDAR_URL"),
+		GraphURL:          os.Getenv("GRAPH_URL"),
+		OAuth: map[string]handlers.OAuthConfig{
+			"google": {
+				ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
+				ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+				RedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
+				SuccessURL:   os.Getenv("GOOGLE_OAUTH_SUCCESS_URL"),
+			},
+			"ms365": {
+				ClientID:     os.Getenv("MS365_CLIENT_ID"),
+				ClientSecret: os.Getenv("MS365_CLIENT_SECRET"),
+				RedirectURL:  os.Getenv("MS365_REDIRECT_URL"),
+				SuccessURL:   os.Getenv("MS365_OAUTH_SUCCESS_URL"),
+			},
+		},
+	}
+	if key := os.Getenv("TOKEN_ENCRYPTION_KEY"); key != "" {
+		secrets, err := secretbox.NewFromBase64(key)
+		if err != nil {
+			log.Fatalf("TOKEN_ENCRYPTION_KEY: %v", err)
+		}
+		app.Secrets = secrets
+	}
+	reflow.NewRunner(app).Start(context.Background())
 
 	router := chi.NewRouter()
 	router.Use(middleware.Logger)
@@ -40,6 +69,8 @@ func main() {
 	})
 
 	router.Get("/api/health", app.Health)
+	router.Get("/api/integrations/{provider}/callback", app.OAuthCallback)
+	router.Get("/api/feed/{token}.ics", app.ServeFeed)
 
 	router.Route("/api", func(r chi.Router) {
 		r.Use(app.AuthMiddleware)
@@ -47,18 +78,54 @@ func main() {
 		r.Post("/tasks", app.CreateTask)
 		r.Patch("/tasks/{id}", app.UpdateTask)
 		r.Delete("/tasks/{id}", app.DeleteTask)
+		r.Post("/tasks/{id}/overrun", app.OverrunTask)
+		r.Post("/tasks/{id}/defer", app.DeferTask)
+		r.Get("/tasks/{id}/timer", app.GetTimer)
+		r.Post("/tasks/{id}/timer/start", app.StartTimer)
+		r.Post("/tasks/{id}/timer/pause", app.PauseTimer)
+		r.Post("/tasks/{id}/timer/resume", app.ResumeTimer)
+		r.Post("/tasks/{id}/timer/stop", app.StopTimer)
+
+		r.Get("/estimates", app.GetEstimates)
+		r.Get("/focus-profile", app.GetFocusProfile)
+		r.Get("/templates", app.GetTemplates)
+		r.Post("/templates", app.CreateTemplate)
+		r.Patch("/templates/{id}", app.UpdateTemplate)
+		r.Delete("/templates/{id}", app.DeleteTemplate)
 
 		r.Get("/projects", app.GetProjects)
 		r.Post("/projects", app.CreateProject)
 
 		r.Get("/events", app.GetEvents)
 		r.Post("/events", app.CreateEvent)
+		r.Patch("/events/{id}/occurrences/{date}", app.OverrideOccurrence)
+		r.Delete("/events/{id}/occurrences/{date}", app.CancelOccurrence)
+		r.Post("/events/{id}/overrun", app.OverrunEvent)
+
+		r.Get("/time-off", app.GetTimeOff)
+		r.Post("/time-off", app.CreateTimeOff)
+		r.Post("/time-off/holidays", app.ImportHolidays)
+		r.Patch("/time-off/{id}", app.UpdateTimeOff)
+		r.Delete("/time-off/{id}", app.DeleteTimeOff)
 
 		r.Get("/settings", app.GetSettings)
 		r.Post("/settings", app.SaveSettings)
 
 		r.Post("/schedule/auto", app.AutoSchedule)
-		r.Post("/integrations/google/import", app.ImportGoogleCalendar)
+		r.Post("/schedule/plans/{id}/apply", app.ApplySchedulePlan)
+		r.Get("/integrations/{provider}/connect", app.ConnectCalendar)
+		r.Delete("/integrations/{provider}", app.DisconnectCalendar)
+		r.Get("/feed", app.GetFeed)
+		r.Post("/feed", app.CreateFeed)
+		r.Patch("/feed", app.UpdateFeed)
+		r.Delete("/feed", app.DeleteFeed)
+		r.Post("/integrations/caldav/connect", app.ConnectCalDAV)
+		r.Post("/integrations/ics/upload", app.UploadICS)
+		r.Get("/integrations/{provider}/calendars", app.GetCalendars)
+		r.Post("/integrations/{provider}/import", app.ImportCalendar)
+		r.Get("/integrations/writeback", app.GetWriteBack)
+		r.Delete("/integrations/writeback", app.DisableWriteBack)
+		r.Post("/integrations/{provider}/writeback", app.EnableWriteBack)
 		r.Post("/ai/breakdown", app.AIBreakdown)
 	})
 
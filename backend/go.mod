@@ -2,4 +2,4 @@ module cal-enderBE
 
 go 1.24.3
 
-require github.com/go-chi/chi/v5 v5.2.4 // indirect
+require github.com/go-chi/chi/v5 v5.2.4
@@ -0,0 +1,294 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const maxResponseBytes = 10 << 20
+
+var ErrUnauthorized = errors.New("caldav: unauthorized")
+
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+type Client struct {
+	BaseURL  string
+	Username string
+	Password string
+	HTTP     *http.Client
+}
+
+type Calendar struct {
+	Href string `json:"href"`
+	Name string `json:"name"`
+	CTag string `json:"ctag,omitempty"`
+}
+
+type Object struct {
+	Href string
+	ETag string
+	Data string
+}
+
+type multistatus struct {
+	Responses []response `xml:"DAV: response"`
+}
+
+type response struct {
+	Href      string     `xml:"DAV: href"`
+	Propstats []propstat `xml:"DAV: propstat"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type hrefProp struct {
+	Href string `xml:"DAV: href"`
+}
+
+type prop struct {
+	CurrentUserPrincipal *hrefProp `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      *hrefProp `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	DisplayName          string    `xml:"DAV: displayname"`
+	ResourceType         struct {
+		Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+	} `xml:"DAV: resourcetype"`
+	ETag         string `xml:"DAV: getetag"`
+	CTag         string `xml:"http://calendarserver.org/ns/ getctag"`
+	CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+func (c *Client) FindCalendars() ([]Calendar, error) {
+	principal, err := c.findHref("", `<d:current-user-principal/>`, func(p prop) *hrefProp { return p.CurrentUserPrincipal })
+	if err != nil {
+		return nil, err
+	}
+	home, err := c.findHref(principal, `<c:calendar-home-set/>`, func(p prop) *hrefProp { return p.CalendarHomeSet })
+	if err != nil {
+		return nil, err
+	}
+	body := `<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">` +
+		`<d:prop><d:resourcetype/><d:displayname/><cs:getctag/></d:prop></d:propfind>`
+	responses, err := c.do("PROPFIND", home, "1", body)
+	if err != nil {
+		return nil, err
+	}
+	calendars := []Calendar{}
+	for _, resp := range responses {
+		p, ok := okProp(resp)
+		if !ok || p.ResourceType.Calendar == nil {
+			continue
+		}
+		name := p.DisplayName
+		if name == "" {
+			name = strings.Trim(resp.Href, "/")
+		}
+		calendars = append(calendars, Calendar{Href: resp.Href, Name: name, CTag: p.CTag})
+	}
+	return calendars, nil
+}
+
+func (c *Client) ListETags(calendarHref string, start, end time.Time) (map[string]string, error) {
+	responses, err := c.do("REPORT", calendarHref, "1", calendarQuery(`<d:getetag/>`, start, end))
+	if err != nil {
+		return nil, err
+	}
+	etags := map[string]string{}
+	for _, resp := range responses {
+		if p, ok := okProp(resp); ok {
+			etags[resp.Href] = p.ETag
+		}
+	}
+	return etags, nil
+}
+
+func (c *Client) QueryEvents(calendarHref string, start, end time.Time) ([]Object, error) {
+	responses, err := c.do("REPORT", calendarHref, "1", calendarQuery(`<d:getetag/><c:calendar-data/>`, start, end))
+	if err != nil {
+		return nil, err
+	}
+	return objects(responses), nil
+}
+
+func (c *Client) MultiGet(calendarHref string, hrefs []string) ([]Object, error) {
+	if len(hrefs) == 0 {
+		return []Object{}, nil
+	}
+	var body strings.Builder
+	body.WriteString(`<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`)
+	body.WriteString(`<d:prop><d:getetag/><c:calendar-data/></d:prop>`)
+	for _, href := range hrefs {
+		body.WriteString("<d:href>")
+		xml.EscapeText(&body, []byte(href))
+		body.WriteString("</d:href>")
+	}
+	body.WriteString(`</c:calendar-multiget>`)
+	responses, err := c.do("REPORT", calendarHref, "1", body.String())
+	if err != nil {
+		return nil, err
+	}
+	return objects(responses), nil
+}
+
+func (c *Client) Put(href, data, etag string) (string, error) {
+	req, err := c.request("PUT", href, data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return "", ErrUnauthorized
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("caldav: PUT %s: %s", href, resp.Status)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+func (c *Client) Delete(href string) error {
+	req, err := c.request("DELETE", href, "")
+	if err != nil {
+		return err
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return ErrUnauthorized
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("caldav: DELETE %s: %s", href, resp.Status)
+	}
+	return nil
+}
+
+func (c *Client) findHref(path, propXML string, pick func(prop) *hrefProp) (string, error) {
+	body := `<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop>` + propXML + `</d:prop></d:propfind>`
+	responses, err := c.do("PROPFIND", path, "0", body)
+	if err != nil {
+		return "", err
+	}
+	for _, resp := range responses {
+		if p, ok := okProp(resp); ok {
+			if href := pick(p); href != nil && href.Href != "" {
+				return href.Href, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("caldav: %s not found", strings.Trim(propXML, "</>"))
+}
+
+func (c *Client) request(method, path, body string) (*http.Request, error) {
+	endpoint, err := c.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewBufferString(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	return req, nil
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return defaultClient
+}
+
+func (c *Client) do(method, path, depth, body string) ([]response, error) {
+	req, err := c.request(method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `application/xml; charset="utf-8"`)
+	req.Header.Set("Depth", depth)
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("caldav: %s %s: %s", method, path, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxResponseBytes {
+		return nil, fmt.Errorf("caldav: %s %s: response is larger than %d bytes", method, path, maxResponseBytes)
+	}
+	var result multistatus
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result.Responses, nil
+}
+
+func (c *Client) resolve(path string) (string, error) {
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		return base.String(), nil
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+func calendarQuery(props string, start, end time.Time) string {
+	return `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">` +
+		`<d:prop>` + props + `</d:prop>` +
+		`<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">` +
+		fmt.Sprintf(`<c:time-range start="%s" end="%s"/>`, start.UTC().Format("20060102T150405Z"), end.UTC().Format("20060102T150405Z")) +
+		`</c:comp-filter></c:comp-filter></c:filter></c:calendar-query>`
+}
+
+func okProp(resp response) (prop, bool) {
+	for _, ps := range resp.Propstats {
+		if ps.Status == "" || strings.Contains(ps.Status, " 200 ") {
+			return ps.Prop, true
+		}
+	}
+	return prop{}, false
+}
+
+func objects(responses []response) []Object {
+	out := []Object{}
+	for _, resp := range responses {
+		p, ok := okProp(resp)
+		if !ok {
+			continue
+		}
+		out = append(out, Object{Href: resp.Href, ETag: p.ETag, Data: p.CalendarData})
+	}
+	return out
+}
@@ -0,0 +1,177 @@
+package calendars
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/caldav"
+	"cal-enderBE/internal/ical"
+)
+
+type caldavProvider struct {
+	client    *caldav.Client
+	loc       *time.Location
+	days      int
+	calendars []caldav.Calendar
+}
+
+type caldavState struct {
+	Day     string                  `json:"day"`
+	CTag    string                  `json:"ctag"`
+	Objects map[string]caldavObject `json:"objects"`
+}
+
+type caldavObject struct {
+	ETag        string   `json:"etag"`
+	ExternalIDs []string `json:"external_ids"`
+}
+
+func NewCalDAV(options Options) Provider {
+	return &caldavProvider{
+		client: &caldav.Client{BaseURL: options.BaseURL, Username: options.Username, Password: options.Password, HTTP: options.httpClient()},
+		loc:    options.Location,
+		days:   options.Days,
+	}
+}
+
+func (c *caldavProvider) ListCalendars() ([]Calendar, error) {
+	found, err := c.discover()
+	if err != nil {
+		return nil, err
+	}
+	calendars := []Calendar{}
+	for i, calendar := range found {
+		calendars = append(calendars, Calendar{ID: calendar.Href, Name: calendar.Name, Primary: i == 0})
+	}
+	return calendars, nil
+}
+
+func (c *caldavProvider) ListEvents(calendarID string, start, end time.Time) ([]Event, error) {
+	calendar, err := c.resolve(calendarID)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := c.client.QueryEvents(calendar.Href, start, end)
+	if err != nil {
+		return nil, c.wrap(err)
+	}
+	events := []Event{}
+	for _, object := range objects {
+		events = append(events, c.objectEvents(object, start, end)...)
+	}
+	return events, nil
+}
+
+func (c *caldavProvider) Changes(calendarID, syncToken string) (ChangeSet, error) {
+	calendar, err := c.resolve(calendarID)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	today := time.Now().In(c.loc).Format("2006-01-02")
+	previous := caldavState{}
+	if syncToken != "" {
+		json.Unmarshal([]byte(syncToken), &previous)
+	}
+	if calendar.CTag != "" && previous.CTag == calendar.CTag && previous.Day == today {
+		return ChangeSet{Changes: []Change{}, SyncToken: syncToken}, nil
+	}
+	start, end := window(c.loc, c.days)
+	etags, err := c.client.ListETags(calendar.Href, start, end)
+	if err != nil {
+		return ChangeSet{}, c.wrap(err)
+	}
+	next := caldavState{Day: today, CTag: calendar.CTag, Objects: map[string]caldavObject{}}
+	changes := []Change{}
+	changed := []string{}
+	for href, etag := range etags {
+		if object, ok := previous.Objects[href]; ok && etag != "" && object.ETag == etag {
+			for _, externalID := range object.ExternalIDs {
+				changes = append(changes, Change{ExternalID: externalID, Unchanged: true})
+			}
+			next.Objects[href] = object
+			continue
+		}
+		changed = append(changed, href)
+	}
+	objects, err := c.client.MultiGet(calendar.Href, changed)
+	if err != nil {
+		return ChangeSet{}, c.wrap(err)
+	}
+	for _, object := range objects {
+		state := caldavObject{ETag: object.ETag}
+		for _, event := range c.objectEvents(object, start, end) {
+			event := event
+			changes = append(changes, Change{ExternalID: event.ExternalID, Event: &event})
+			state.ExternalIDs = append(state.ExternalIDs, event.ExternalID)
+		}
+		next.Objects[object.Href] = state
+	}
+	token, err := json.Marshal(next)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	return ChangeSet{Changes: changes, SyncToken: string(token), Complete: true}, nil
+}
+
+func (c *caldavProvider) WriteEvent(calendarID string, event Event) (Event, error) {
+	calendar, err := c.resolve(calendarID)
+	if err != nil {
+		return Event{}, err
+	}
+	href := event.ExternalID
+	if href == "" {
+		href = strings.TrimSuffix(calendar.Href, "/") + "/" + newUID() + ".ics"
+	}
+	uid := strings.TrimSuffix(href[strings.LastIndex(href, "/")+1:], ".ics")
+	data := ical.Marshal("", []ical.Event{{UID: uid, Summary: event.Title, Start: event.Start, End: event.End, AllDay: event.AllDay}})
+	if _, err := c.client.Put(href, data, ""); err != nil {
+		return Event{}, c.wrap(err)
+	}
+	event.ExternalID = href
+	return event, nil
+}
+
+func (c *caldavProvider) DeleteEvent(calendarID, externalID string) error {
+	return c.wrap(c.client.Delete(externalID))
+}
+
+func (c *caldavProvider) discover() ([]caldav.Calendar, error) {
+	if c.calendars == nil {
+		found, err := c.client.FindCalendars()
+		if err != nil {
+			return nil, c.wrap(err)
+		}
+		c.calendars = found
+	}
+	return c.calendars, nil
+}
+
+func (c *caldavProvider) resolve(calendarID string) (caldav.Calendar, error) {
+	found, err := c.discover()
+	if err != nil {
+		return caldav.Calendar{}, err
+	}
+	for i, calendar := range found {
+		if calendar.Href == calendarID || (calendarID == "primary" && i == 0) {
+			return calendar, nil
+		}
+	}
+	return caldav.Calendar{}, ErrNotFound
+}
+
+func (c *caldavProvider) objectEvents(object caldav.Object, start, end time.Time) []Event {
+	events, err := icalEvents(object.Data, object.Href, start, end, c.loc)
+	if err != nil {
+		return nil
+	}
+	return events
+}
+
+func (c *caldavProvider) wrap(err error) error {
+	if errors.Is(err, caldav.ErrUnauthorized) {
+		return ErrUnauthorized
+	}
+	return err
+}
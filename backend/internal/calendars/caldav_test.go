@@ -0,0 +1,184 @@
+package calendars
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeCalDAVObject struct {
+	etag string
+	data string
+}
+
+type fakeCalDAV struct {
+	mu       sync.Mutex
+	ctag     string
+	objects  map[string]fakeCalDAVObject
+	multiGet []string
+	reports  int
+}
+
+func (f *fakeCalDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	var out strings.Builder
+	out.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">`)
+	switch {
+	case r.Method == "PROPFIND" && r.URL.Path == "/":
+		out.WriteString(fakeResponse("/", `<d:current-user-principal><d:href>/principals/me/</d:href></d:current-user-principal>`))
+	case r.Method == "PROPFIND" && r.URL.Path == "/principals/me/":
+		out.WriteString(fakeResponse("/principals/me/", `<c:calendar-home-set><d:href>/calendars/me/</d:href></c:calendar-home-set>`))
+	case r.Method == "PROPFIND" && r.URL.Path == "/calendars/me/":
+		out.WriteString(fakeResponse("/calendars/me/", `<d:resourcetype><d:collection/></d:resourcetype>`))
+		out.WriteString(fakeResponse("/calendars/me/work/", `<d:resourcetype><d:collection/><c:calendar/></d:resourcetype><d:displayname>Work</d:displayname><cs:getctag>`+f.ctag+`</cs:getctag>`))
+	case r.Method == "REPORT" && r.URL.Path == "/calendars/me/work/":
+		f.reports++
+		if strings.Contains(string(body), "calendar-multiget") {
+			var request struct {
+				Hrefs []string `xml:"DAV: href"`
+			}
+			xml.Unmarshal(body, &request)
+			for _, href := range request.Hrefs {
+				f.multiGet = append(f.multiGet, href)
+				if object, ok := f.objects[href]; ok {
+					out.WriteString(fakeResponse(href, fmt.Sprintf(`<d:getetag>%s</d:getetag><c:calendar-data>%s</c:calendar-data>`, object.etag, escapeXML(object.data))))
+				}
+			}
+			break
+		}
+		for href, object := range f.objects {
+			out.WriteString(fakeResponse(href, `<d:getetag>`+object.etag+`</d:getetag>`))
+		}
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	out.WriteString(`</d:multistatus>`)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, out.String())
+}
+
+func (f *fakeCalDAV) takeMultiGet() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hrefs := f.multiGet
+	f.multiGet = nil
+	sort.Strings(hrefs)
+	return hrefs
+}
+
+func (f *fakeCalDAV) reportCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reports
+}
+
+func fakeResponse(href, props string) string {
+	return `<d:response><d:href>` + href + `</d:href><d:propstat><d:prop>` + props + `</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`
+}
+
+func escapeXML(value string) string {
+	var out strings.Builder
+	xml.EscapeText(&out, []byte(value))
+	return out.String()
+}
+
+func fakeVEvent(uid, summary string, start time.Time) string {
+	return strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"SUMMARY:" + summary,
+		"DTSTART:" + start.UTC().Format("20060102T150405Z"),
+		"DTEND:" + start.Add(time.Hour).UTC().Format("20060102T150405Z"),
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+}
+
+func changeIndex(changes []Change) map[string]Change {
+	index := map[string]Change{}
+	for _, change := range changes {
+		index[change.ExternalID] = change
+	}
+	return index
+}
+
+func TestCalDAVChangesUsesETagsAndCTag(t *testing.T) {
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	fake := &fakeCalDAV{
+		ctag: "ctag-1",
+		objects: map[string]fakeCalDAVObject{
+			"/calendars/me/work/a.ics": {etag: `"a1"`, data: fakeVEvent("a", "Standup", start)},
+			"/calendars/me/work/b.ics": {etag: `"b1"`, data: fakeVEvent("b", "Review", start.Add(2*time.Hour))},
+			"/calendars/me/work/c.ics": {etag: `"c1"`, data: fakeVEvent("c", "Retro", start.Add(4*time.Hour))},
+		},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	fetch := func(token string) ChangeSet {
+		t.Helper()
+		provider, err := New("caldav", Options{BaseURL: server.URL + "/", Username: "me", Password: "secret", Location: time.UTC, HTTP: server.Client()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		set, err := provider.Changes("primary", token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+
+	first := fetch("")
+	if !first.Complete || len(first.Changes) != 3 {
+		t.Fatalf("first sync: complete=%v changes=%d, want complete with 3 changes", first.Complete, len(first.Changes))
+	}
+	if got := fake.takeMultiGet(); len(got) != 3 {
+		t.Fatalf("first sync fetched %v, want all three objects", got)
+	}
+
+	reports := fake.reportCount()
+	same := fetch(first.SyncToken)
+	if len(same.Changes) != 0 || same.SyncToken != first.SyncToken || fake.reportCount() != reports {
+		t.Fatalf("unchanged ctag: got %d changes and %d reports, want a short-circuit", len(same.Changes), fake.reportCount()-reports)
+	}
+
+	fake.mu.Lock()
+	fake.ctag = "ctag-2"
+	fake.objects["/calendars/me/work/b.ics"] = fakeCalDAVObject{etag: `"b2"`, data: fakeVEvent("b", "Design review", start.Add(3*time.Hour))}
+	delete(fake.objects, "/calendars/me/work/c.ics")
+	fake.mu.Unlock()
+
+	next := fetch(first.SyncToken)
+	if got := fake.takeMultiGet(); len(got) != 1 || got[0] != "/calendars/me/work/b.ics" {
+		t.Fatalf("second sync fetched %v, want only the changed object", got)
+	}
+	changes := changeIndex(next.Changes)
+	if change := changes["/calendars/me/work/a.ics"]; !change.Unchanged {
+		t.Errorf("unchanged object: got %+v, want Unchanged", change)
+	}
+	if change := changes["/calendars/me/work/b.ics"]; change.Event == nil || change.Event.Title != "Design review" {
+		t.Errorf("changed object: got %+v, want the new title", change)
+	}
+	if _, ok := changes["/calendars/me/work/c.ics"]; ok {
+		t.Errorf("deleted object still reported")
+	}
+	if !next.Complete {
+		t.Errorf("etag sync must be complete so deleted objects are removed")
+	}
+	if strings.Contains(next.SyncToken, "c.ics") || !strings.Contains(next.SyncToken, `\"b2\"`) {
+		t.Errorf("sync token not updated: %s", next.SyncToken)
+	}
+}
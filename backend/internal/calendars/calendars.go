@@ -0,0 +1,215 @@
+package calendars
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const SyncDays = 30
+
+var (
+	ErrUnknownProvider  = errors.New("unknown calendar provider")
+	ErrSyncTokenExpired = errors.New("sync token expired")
+	ErrUnauthorized     = errors.New("calendar credentials rejected")
+	ErrNotFound         = errors.New("calendar or event not found")
+	ErrReadOnly         = errors.New("calendar provider is read-only")
+)
+
+type Calendar struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Primary bool   `json:"primary"`
+}
+
+type Event struct {
+	ExternalID string
+	Title      string
+	Start      time.Time
+	End        time.Time
+	AllDay     bool
+}
+
+type Change struct {
+	ExternalID string
+	Deleted    bool
+	Unchanged  bool
+	Event      *Event
+}
+
+type ChangeSet struct {
+	Changes   []Change
+	SyncToken string
+	Complete  bool
+}
+
+type Provider interface {
+	ListCalendars() ([]Calendar, error)
+	ListEvents(calendarID string, start, end time.Time) ([]Event, error)
+	Changes(calendarID, syncToken string) (ChangeSet, error)
+	WriteEvent(calendarID string, event Event) (Event, error)
+	DeleteEvent(calendarID, externalID string) error
+}
+
+type Options struct {
+	BaseURL     string
+	APIKey      string
+	AccessToken string
+	Username    string
+	Password    string
+	Data        string
+	Days        int
+	Location    *time.Location
+	HTTP        *http.Client
+}
+
+func (o Options) httpClient() *http.Client {
+	if o.HTTP != nil {
+		return o.HTTP
+	}
+	return publicClient
+}
+
+// apiClient calls the Google and Graph APIs. Their addresses come from our
+// own configuration, so it only needs a timeout, not the public-only dialer.
+var apiClient = &http.Client{Timeout: 30 * time.Second}
+
+// APIClient returns the HTTP client for provider APIs and token endpoints.
+func APIClient() *http.Client {
+	return apiClient
+}
+
+func (o Options) apiClient() *http.Client {
+	if o.HTTP != nil {
+		return o.HTTP
+	}
+	return apiClient
+}
+
+type Factory func(Options) Provider
+
+var registry = map[string]Factory{
+	"google": NewGoogle,
+	"ms365":  NewMicrosoft,
+	"caldav": NewCalDAV,
+	"ics":    NewICS,
+}
+
+func New(name string, options Options) (Provider, error) {
+	factory, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+	if options.Days <= 0 {
+		options.Days = SyncDays
+	}
+	return factory(options), nil
+}
+
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func Dedupe(changes []Change) []Change {
+	last := map[string]int{}
+	for i, change := range changes {
+		last[change.ExternalID] = i
+	}
+	out := make([]Change, 0, len(last))
+	for i, change := range changes {
+		if last[change.ExternalID] == i {
+			out = append(out, change)
+		}
+	}
+	return out
+}
+
+func window(loc *time.Location, days int) (time.Time, time.Time) {
+	now := time.Now().In(loc)
+	return now, now.AddDate(0, 0, days)
+}
+
+func normalize(event Event, loc *time.Location) *Event {
+	if event.Start.IsZero() || event.End.IsZero() {
+		return nil
+	}
+	event.Title = strings.TrimSpace(event.Title)
+	if !event.AllDay {
+		event.Start = event.Start.In(loc)
+		event.End = event.End.In(loc)
+	}
+	if event.End.Before(event.Start) {
+		event.End = event.Start
+	}
+	return &event
+}
+
+func newUID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func parseDate(value string, loc *time.Location) time.Time {
+	if strings.Contains(value, "T") {
+		t, _ := time.Parse(time.RFC3339, value)
+		return t.In(loc)
+	}
+	t, _ := time.ParseInLocation("2006-01-02", value, loc)
+	return t
+}
+
+func newRequest(method, endpoint string, body any) (*http.Request, error) {
+	if body == nil {
+		return http.NewRequest(method, endpoint, nil)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func doJSON(name string, client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusGone:
+		return ErrSyncTokenExpired
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("%s api error: %s", name, resp.Status)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,214 @@
+package calendars
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const googleCalendarURL = "https://www.googleapis.com/calendar/v3"
+
+type googleTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+}
+
+type googleEvent struct {
+	ID           string     `json:"id,omitempty"`
+	Status       string     `json:"status,omitempty"`
+	Summary      string     `json:"summary"`
+	Transparency string     `json:"transparency,omitempty"`
+	Start        googleTime `json:"start"`
+	End          googleTime `json:"end"`
+}
+
+type google struct {
+	baseURL     string
+	apiKey      string
+	accessToken string
+	loc         *time.Location
+	days        int
+	client      *http.Client
+}
+
+func NewGoogle(options Options) Provider {
+	baseURL := strings.TrimSuffix(options.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = googleCalendarURL
+	}
+	return &google{baseURL: baseURL, apiKey: options.APIKey, accessToken: options.AccessToken, loc: options.Location, days: options.Days, client: options.apiClient()}
+}
+
+func (g *google) ListCalendars() ([]Calendar, error) {
+	calendars := []Calendar{}
+	query := url.Values{}
+	for {
+		var page struct {
+			Items []struct {
+				ID      string `json:"id"`
+				Summary string `json:"summary"`
+				Primary bool   `json:"primary"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := g.do("GET", "/users/me/calendarList", query, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			calendars = append(calendars, Calendar{ID: item.ID, Name: item.Summary, Primary: item.Primary})
+		}
+		if page.NextPageToken == "" {
+			return calendars, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+func (g *google) ListEvents(calendarID string, start, end time.Time) ([]Event, error) {
+	query := url.Values{}
+	query.Set("singleEvents", "true")
+	query.Set("timeMin", start.Format(time.RFC3339))
+	query.Set("timeMax", end.Format(time.RFC3339))
+	changes, _, err := g.fetch(calendarID, query)
+	if err != nil {
+		return nil, err
+	}
+	events := []Event{}
+	for _, change := range changes {
+		if change.Event != nil {
+			events = append(events, *change.Event)
+		}
+	}
+	return events, nil
+}
+
+func (g *google) Changes(calendarID, syncToken string) (ChangeSet, error) {
+	query := url.Values{}
+	query.Set("singleEvents", "true")
+	if syncToken != "" {
+		query.Set("syncToken", syncToken)
+	} else {
+		start, end := window(g.loc, g.days)
+		query.Set("timeMin", start.Format(time.RFC3339))
+		query.Set("timeMax", end.Format(time.RFC3339))
+	}
+	changes, nextSyncToken, err := g.fetch(calendarID, query)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	return ChangeSet{Changes: changes, SyncToken: nextSyncToken, Complete: syncToken == ""}, nil
+}
+
+func (g *google) WriteEvent(calendarID string, event Event) (Event, error) {
+	body := googleEvent{Summary: event.Title, Transparency: "opaque"}
+	if event.AllDay {
+		body.Start.Date = event.Start.Format("2006-01-02")
+		body.End.Date = event.End.Format("2006-01-02")
+	} else {
+		body.Start.DateTime = event.Start.Format(time.RFC3339)
+		body.End.DateTime = event.End.Format(time.RFC3339)
+	}
+	path := fmt.Sprintf("/calendars/%s/events", url.PathEscape(calendarID))
+	method := "POST"
+	if event.ExternalID != "" {
+		path += "/" + url.PathEscape(event.ExternalID)
+		method = "PUT"
+	}
+	var created googleEvent
+	if err := g.do(method, path, nil, body, &created); err != nil {
+		return Event{}, err
+	}
+	event.ExternalID = created.ID
+	return event, nil
+}
+
+func (g *google) DeleteEvent(calendarID, externalID string) error {
+	path := fmt.Sprintf("/calendars/%s/events/%s", url.PathEscape(calendarID), url.PathEscape(externalID))
+	err := g.do("DELETE", path, nil, nil, nil)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrSyncTokenExpired) {
+		return nil
+	}
+	return err
+}
+
+func (g *google) fetch(calendarID string, query url.Values) ([]Change, string, error) {
+	changes := []Change{}
+	for {
+		var page struct {
+			Items         []googleEvent `json:"items"`
+			NextPageToken string        `json:"nextPageToken"`
+			NextSyncToken string        `json:"nextSyncToken"`
+		}
+		path := fmt.Sprintf("/calendars/%s/events", url.PathEscape(calendarID))
+		if err := g.do("GET", path, query, nil, &page); err != nil {
+			return nil, "", err
+		}
+		for _, item := range page.Items {
+			// Events marked free don't block time, so they are dropped like
+			// cancelled ones, which also clears a copy imported while busy.
+			if item.Status == "cancelled" || item.Transparency == "transparent" {
+				changes = append(changes, Change{ExternalID: item.ID, Deleted: true})
+				continue
+			}
+			changes = append(changes, Change{ExternalID: item.ID, Event: g.event(item)})
+		}
+		if page.NextPageToken == "" {
+			return changes, page.NextSyncToken, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+func (g *google) event(item googleEvent) *Event {
+	start := item.Start.DateTime
+	end := item.End.DateTime
+	if start == "" {
+		start = item.Start.Date
+	}
+	if end == "" {
+		end = item.End.Date
+	}
+	if start == "" || end == "" {
+		return nil
+	}
+	return normalize(Event{
+		ExternalID: item.ID,
+		Title:      item.Summary,
+		Start:      parseDate(start, g.loc),
+		End:        parseDate(end, g.loc),
+		AllDay:     item.Start.DateTime == "",
+	}, g.loc)
+}
+
+func (g *google) do(method, path string, query url.Values, body, out any) error {
+	endpoint := g.baseURL + path
+	if g.accessToken == "" {
+		if query == nil {
+			query = url.Values{}
+		}
+		query = cloneValues(query)
+		query.Set("key", g.apiKey)
+	}
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := newRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if g.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+g.accessToken)
+	}
+	return doJSON("google", g.client, req, out)
+}
+
+func cloneValues(values url.Values) url.Values {
+	out := url.Values{}
+	for key, list := range values {
+		out[key] = append([]string(nil), list...)
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+package calendars
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"cal-enderBE/internal/ical"
+)
+
+const maxFeedBytes = 10 << 20
+
+var errPrivateAddress = errors.New("calendar address is not public")
+
+// publicClient is used for every URL a user supplies. The dialer refuses
+// loopback, private and link-local addresses, so neither the URL itself nor
+// a redirect or discovered href can reach into our own network.
+var publicClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: 10 * time.Second, Control: publicOnly}).DialContext,
+	},
+}
+
+type icsProvider struct {
+	data   string
+	loc    *time.Location
+	days   int
+	client *http.Client
+}
+
+func NewICS(options Options) Provider {
+	return &icsProvider{data: options.Data, loc: options.Location, days: options.Days, client: options.httpClient()}
+}
+
+func (p *icsProvider) ListCalendars() ([]Calendar, error) {
+	return []Calendar{}, nil
+}
+
+func (p *icsProvider) ListEvents(calendarID string, start, end time.Time) ([]Event, error) {
+	data, err := p.load(calendarID)
+	if err != nil {
+		return nil, err
+	}
+	return icalEvents(data, "", start, end, p.loc)
+}
+
+func (p *icsProvider) Changes(calendarID, syncToken string) (ChangeSet, error) {
+	data, err := p.load(calendarID)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	sum := sha256.Sum256([]byte(data))
+	token := time.Now().In(p.loc).Format("2006-01-02") + "|" + hex.EncodeToString(sum[:])
+	if token == syncToken {
+		return ChangeSet{Changes: []Change{}, SyncToken: token}, nil
+	}
+	start, end := window(p.loc, p.days)
+	events, err := icalEvents(data, "", start, end, p.loc)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	changes := make([]Change, 0, len(events))
+	for i := range events {
+		changes = append(changes, Change{ExternalID: events[i].ExternalID, Event: &events[i]})
+	}
+	return ChangeSet{Changes: changes, SyncToken: token, Complete: true}, nil
+}
+
+func (p *icsProvider) WriteEvent(calendarID string, event Event) (Event, error) {
+	return Event{}, ErrReadOnly
+}
+
+func (p *icsProvider) DeleteEvent(calendarID, externalID string) error {
+	return ErrReadOnly
+}
+
+func (p *icsProvider) load(calendarID string) (string, error) {
+	if p.data != "" {
+		return p.data, nil
+	}
+	feedURL, err := FeedURL(calendarID)
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.client.Get(feedURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return "", ErrNotFound
+	case resp.StatusCode >= 300:
+		return "", fmt.Errorf("ics feed error: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxFeedBytes {
+		return "", fmt.Errorf("ics feed is larger than %d bytes", maxFeedBytes)
+	}
+	return string(data), nil
+}
+
+func FeedURL(value string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("%w: %q is not a feed url", ErrNotFound, value)
+	}
+	switch parsed.Scheme {
+	case "webcal", "webcals":
+		parsed.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("%w: %q is not a feed url", ErrNotFound, value)
+	}
+	return parsed.String(), nil
+}
+
+func icalEvents(data, prefix string, start, end time.Time, loc *time.Location) ([]Event, error) {
+	parsed, err := ical.Parse(data, loc)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := ical.Expand(parsed, start, end)
+	if err != nil {
+		return nil, err
+	}
+	events := []Event{}
+	for _, item := range expanded {
+		if item.Status == "CANCELLED" {
+			continue
+		}
+		externalID := prefix
+		if externalID == "" {
+			externalID = item.UID
+		}
+		if externalID == "" {
+			externalID = item.Start.UTC().Format("20060102T150405Z") + "-" + item.Summary
+		}
+		if item.RecurrenceID != "" {
+			externalID += "#" + item.RecurrenceID
+		}
+		event := normalize(Event{ExternalID: externalID, Title: item.Summary, Start: item.Start, End: item.End, AllDay: item.AllDay}, loc)
+		if event != nil {
+			events = append(events, *event)
+		}
+	}
+	return events, nil
+}
+
+// PublicClient returns the HTTP client for user-supplied server addresses.
+func PublicClient() *http.Client {
+	return publicClient
+}
+
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return errPrivateAddress
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return errPrivateAddress
+	}
+	for _, prefix := range nonPublicRanges {
+		if prefix.Contains(ip) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// nonPublicRanges are the ranges IsGlobalUnicast and IsPrivate let through
+// that still never belong to a calendar server on the internet.
+var nonPublicRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
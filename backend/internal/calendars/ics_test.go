@@ -0,0 +1,39 @@
+package calendars
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestPublicOnly(t *testing.T) {
+	tests := []struct {
+		host   string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"192.168.0.10", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+	for _, test := range tests {
+		err := publicOnly("tcp", net.JoinHostPort(test.host, "443"), nil)
+		if test.public && err != nil {
+			t.Errorf("%s: got %v, want it allowed", test.host, err)
+		}
+		if !test.public && !errors.Is(err, errPrivateAddress) {
+			t.Errorf("%s: got %v, want errPrivateAddress", test.host, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package calendars
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const graphURL = "https://graph.microsoft.com/v1.0"
+
+type graphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphEvent struct {
+	ID       string        `json:"id,omitempty"`
+	Subject  string        `json:"subject"`
+	IsAllDay bool          `json:"isAllDay"`
+	ShowAs   string        `json:"showAs,omitempty"`
+	Start    graphDateTime `json:"start"`
+	End      graphDateTime `json:"end"`
+	Removed  *struct {
+		Reason string `json:"reason"`
+	} `json:"@removed,omitempty"`
+}
+
+type microsoft struct {
+	baseURL     string
+	accessToken string
+	loc         *time.Location
+	days        int
+	client      *http.Client
+}
+
+func NewMicrosoft(options Options) Provider {
+	baseURL := strings.TrimSuffix(options.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = graphURL
+	}
+	return &microsoft{baseURL: baseURL, accessToken: options.AccessToken, loc: options.Location, days: options.Days, client: options.apiClient()}
+}
+
+func (m *microsoft) ListCalendars() ([]Calendar, error) {
+	calendars := []Calendar{}
+	endpoint := m.baseURL + "/me/calendars"
+	for endpoint != "" {
+		var page struct {
+			Value []struct {
+				ID                string `json:"id"`
+				Name              string `json:"name"`
+				IsDefaultCalendar bool   `json:"isDefaultCalendar"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if err := m.do("GET", endpoint, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Value {
+			calendars = append(calendars, Calendar{ID: item.ID, Name: item.Name, Primary: item.IsDefaultCalendar})
+		}
+		endpoint = page.NextLink
+	}
+	return calendars, nil
+}
+
+func (m *microsoft) ListEvents(calendarID string, start, end time.Time) ([]Event, error) {
+	changes, _, err := m.fetch(m.viewURL(calendarID, "calendarView", start, end))
+	if err != nil {
+		return nil, err
+	}
+	events := []Event{}
+	for _, change := range changes {
+		if change.Event != nil {
+			events = append(events, *change.Event)
+		}
+	}
+	return events, nil
+}
+
+func (m *microsoft) Changes(calendarID, deltaLink string) (ChangeSet, error) {
+	endpoint := deltaLink
+	if endpoint == "" {
+		start, end := window(m.loc, m.days)
+		endpoint = m.viewURL(calendarID, "calendarView/delta", start, end)
+	}
+	changes, nextDeltaLink, err := m.fetch(endpoint)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	return ChangeSet{Changes: changes, SyncToken: nextDeltaLink, Complete: deltaLink == ""}, nil
+}
+
+func (m *microsoft) WriteEvent(calendarID string, event Event) (Event, error) {
+	body := graphEvent{Subject: event.Title, IsAllDay: event.AllDay, ShowAs: "busy"}
+	layout := "2006-01-02T15:04:05"
+	start, end := event.Start.UTC(), event.End.UTC()
+	if event.AllDay {
+		layout = "2006-01-02T00:00:00"
+		start, end = event.Start, event.End
+	}
+	body.Start = graphDateTime{DateTime: start.Format(layout), TimeZone: "UTC"}
+	body.End = graphDateTime{DateTime: end.Format(layout), TimeZone: "UTC"}
+	endpoint := m.baseURL + m.calendarPath(calendarID) + "/events"
+	method := "POST"
+	if event.ExternalID != "" {
+		endpoint = m.baseURL + "/me/events/" + url.PathEscape(event.ExternalID)
+		method = "PATCH"
+	}
+	var created graphEvent
+	if err := m.do(method, endpoint, body, &created); err != nil {
+		return Event{}, err
+	}
+	event.ExternalID = created.ID
+	return event, nil
+}
+
+func (m *microsoft) DeleteEvent(calendarID, externalID string) error {
+	err := m.do("DELETE", m.baseURL+"/me/events/"+url.PathEscape(externalID), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (m *microsoft) calendarPath(calendarID string) string {
+	if calendarID == "primary" || calendarID == "" {
+		return "/me"
+	}
+	return "/me/calendars/" + url.PathEscape(calendarID)
+}
+
+func (m *microsoft) viewURL(calendarID, view string, start, end time.Time) string {
+	query := url.Values{}
+	query.Set("startDateTime", start.UTC().Format(time.RFC3339))
+	query.Set("endDateTime", end.UTC().Format(time.RFC3339))
+	return fmt.Sprintf("%s%s/%s?%s", m.baseURL, m.calendarPath(calendarID), view, query.Encode())
+}
+
+func (m *microsoft) fetch(endpoint string) ([]Change, string, error) {
+	changes := []Change{}
+	for {
+		var page struct {
+			Value     []graphEvent `json:"value"`
+			NextLink  string       `json:"@odata.nextLink"`
+			DeltaLink string       `json:"@odata.deltaLink"`
+		}
+		if err := m.do("GET", endpoint, nil, &page); err != nil {
+			return nil, "", err
+		}
+		for _, item := range page.Value {
+			if item.Removed != nil || item.ShowAs == "free" {
+				changes = append(changes, Change{ExternalID: item.ID, Deleted: true})
+				continue
+			}
+			changes = append(changes, Change{ExternalID: item.ID, Event: m.event(item)})
+		}
+		if page.NextLink == "" {
+			return changes, page.DeltaLink, nil
+		}
+		endpoint = page.NextLink
+	}
+}
+
+func (m *microsoft) event(item graphEvent) *Event {
+	start, err := parseGraphDateTime(item.Start, item.IsAllDay, m.loc)
+	if err != nil {
+		return nil
+	}
+	end, err := parseGraphDateTime(item.End, item.IsAllDay, m.loc)
+	if err != nil {
+		return nil
+	}
+	return normalize(Event{ExternalID: item.ID, Title: item.Subject, Start: start, End: end, AllDay: item.IsAllDay}, m.loc)
+}
+
+func (m *microsoft) do(method, endpoint string, body, out any) error {
+	req, err := newRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+	req.Header.Set("Prefer", `outlook.timezone="UTC", odata.maxpagesize=100`)
+	return doJSON("graph", m.client, req, out)
+}
+
+func parseGraphDateTime(value graphDateTime, allDay bool, loc *time.Location) (time.Time, error) {
+	if len(value.DateTime) < 10 {
+		return time.Time{}, fmt.Errorf("invalid graph date %q", value.DateTime)
+	}
+	if allDay {
+		return time.ParseInLocation("2006-01-02", value.DateTime[:10], loc)
+	}
+	source := time.UTC
+	if value.TimeZone != "" && value.TimeZone != "UTC" {
+		if tz, err := time.LoadLocation(value.TimeZone); err == nil {
+			source = tz
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04:05.9999999", value.DateTime, source)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
@@ -0,0 +1,181 @@
+package estimation
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	PriorStrength = 5.0
+	minRatio      = 0.25
+	maxRatio      = 4.0
+)
+
+type Sample struct {
+	Company          string
+	Project          string
+	EstimatedMinutes int
+	ActualMinutes    int
+}
+
+type Factor struct {
+	Company  string  `json:"company,omitempty"`
+	Project  string  `json:"project,omitempty"`
+	Size     string  `json:"size,omitempty"`
+	Samples  int     `json:"samples"`
+	Observed float64 `json:"observed"`
+	Factor   float64 `json:"factor"`
+}
+
+type Model struct {
+	Global    Factor   `json:"global"`
+	Sizes     []Factor `json:"sizes"`
+	Companies []Factor `json:"companies"`
+	Projects  []Factor `json:"projects"`
+	logs      map[string]float64
+}
+
+type group struct {
+	factor Factor
+	sum    float64
+}
+
+func SizeBucket(minutes int) string {
+	switch {
+	case minutes <= 60:
+		return "short"
+	case minutes <= 240:
+		return "medium"
+	case minutes <= 480:
+		return "long"
+	}
+	return "multi_day"
+}
+
+func Fit(samples []Sample) Model {
+	global := group{}
+	sizes := map[string]*group{}
+	companies := map[string]*group{}
+	projects := map[string]*group{}
+	for _, sample := range samples {
+		if sample.EstimatedMinutes <= 0 || sample.ActualMinutes <= 0 {
+			continue
+		}
+		ratio := math.Log(math.Min(math.Max(float64(sample.ActualMinutes)/float64(sample.EstimatedMinutes), minRatio), maxRatio))
+		company, project := normalize(sample.Company), normalize(sample.Project)
+		size := SizeBucket(sample.EstimatedMinutes)
+		global.add(ratio)
+		collect(sizes, sizeKey(size), Factor{Size: size}).add(ratio)
+		if company != "" {
+			collect(companies, companyKey(company, size), Factor{Company: sample.Company, Size: size}).add(ratio)
+		}
+		if project != "" {
+			collect(projects, projectKey(company, project, size), Factor{Company: sample.Company, Project: sample.Project, Size: size}).add(ratio)
+		}
+	}
+
+	model := Model{Sizes: []Factor{}, Companies: []Factor{}, Projects: []Factor{}, logs: map[string]float64{}}
+	globalLog := global.shrink(0)
+	model.Global = global.result(globalLog)
+	model.logs[""] = globalLog
+	for key, size := range sizes {
+		model.logs[key] = size.shrink(globalLog)
+		model.Sizes = append(model.Sizes, size.result(model.logs[key]))
+	}
+	for key, company := range companies {
+		model.logs[key] = company.shrink(model.logs[sizeKey(company.factor.Size)])
+		model.Companies = append(model.Companies, company.result(model.logs[key]))
+	}
+	for key, project := range projects {
+		parent, ok := model.logs[companyKey(normalize(project.factor.Company), project.factor.Size)]
+		if !ok {
+			parent = model.logs[sizeKey(project.factor.Size)]
+		}
+		model.logs[key] = project.shrink(parent)
+		model.Projects = append(model.Projects, project.result(model.logs[key]))
+	}
+	sortFactors(model.Sizes)
+	sortFactors(model.Companies)
+	sortFactors(model.Projects)
+	return model
+}
+
+func (m Model) FactorFor(company, project string, estimatedMinutes int) float64 {
+	if m.logs == nil {
+		return 1
+	}
+	company, project = normalize(company), normalize(project)
+	size := SizeBucket(estimatedMinutes)
+	for _, key := range []string{projectKey(company, project, size), companyKey(company, size), sizeKey(size), ""} {
+		if value, ok := m.logs[key]; ok {
+			return math.Exp(value)
+		}
+	}
+	return 1
+}
+
+func (m Model) Adjust(company, project string, hours float64) float64 {
+	if hours <= 0 {
+		return hours
+	}
+	return hours * m.FactorFor(company, project, int(math.Round(hours*60)))
+}
+
+func (g *group) add(ratio float64) {
+	g.factor.Samples++
+	g.sum += ratio
+}
+
+func (g *group) shrink(parent float64) float64 {
+	return (g.sum + PriorStrength*parent) / (float64(g.factor.Samples) + PriorStrength)
+}
+
+func (g *group) result(value float64) Factor {
+	factor := g.factor
+	factor.Observed = 1
+	if factor.Samples > 0 {
+		factor.Observed = round(math.Exp(g.sum / float64(factor.Samples)))
+	}
+	factor.Factor = round(math.Exp(value))
+	return factor
+}
+
+func collect(groups map[string]*group, key string, factor Factor) *group {
+	if existing, ok := groups[key]; ok {
+		return existing
+	}
+	groups[key] = &group{factor: factor}
+	return groups[key]
+}
+
+func sortFactors(factors []Factor) {
+	sort.Slice(factors, func(i, j int) bool {
+		if factors[i].Samples != factors[j].Samples {
+			return factors[i].Samples > factors[j].Samples
+		}
+		left := factors[i].Company + "|" + factors[i].Project + "|" + factors[i].Size
+		right := factors[j].Company + "|" + factors[j].Project + "|" + factors[j].Size
+		return left < right
+	})
+}
+
+func sizeKey(size string) string {
+	return "size|" + size
+}
+
+func companyKey(company, size string) string {
+	return "company|" + company + "|" + size
+}
+
+func projectKey(company, project, size string) string {
+	return "project|" + company + "|" + project + "|" + size
+}
+
+func normalize(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,79 @@
+package estimation
+
+import "math"
+
+const (
+	FocusPriorMinutes = 120.0
+	minFocusMinutes   = 600
+	peakScore         = 1.1
+	troughScore       = 0.9
+)
+
+const (
+	FocusPeak    = "peak"
+	FocusNeutral = "neutral"
+	FocusTrough  = "trough"
+)
+
+type FocusSample struct {
+	Start            int
+	End              int
+	EstimatedMinutes int
+	ActualMinutes    int
+}
+
+type FocusHour struct {
+	Hour    int     `json:"hour"`
+	Minutes int     `json:"minutes"`
+	Score   float64 `json:"score"`
+	Level   string  `json:"level"`
+}
+
+type FocusProfile struct {
+	Minutes int         `json:"minutes"`
+	Learned bool        `json:"learned"`
+	Hours   []FocusHour `json:"hours"`
+}
+
+func FitFocus(samples []FocusSample) FocusProfile {
+	var minutes [24]float64
+	var sums [24]float64
+	total, totalSum := 0.0, 0.0
+	for _, sample := range samples {
+		if sample.EstimatedMinutes <= 0 || sample.ActualMinutes <= 0 || sample.End <= sample.Start {
+			continue
+		}
+		efficiency := math.Log(math.Min(math.Max(float64(sample.EstimatedMinutes)/float64(sample.ActualMinutes), minRatio), maxRatio))
+		for hour := sample.Start / 60; hour < 24 && hour*60 < sample.End; hour++ {
+			overlap := math.Min(float64(sample.End), float64((hour+1)*60)) - math.Max(float64(sample.Start), float64(hour*60))
+			if overlap <= 0 {
+				continue
+			}
+			minutes[hour] += overlap
+			sums[hour] += overlap * efficiency
+			total += overlap
+			totalSum += overlap * efficiency
+		}
+	}
+
+	profile := FocusProfile{Minutes: int(total), Learned: total >= minFocusMinutes, Hours: []FocusHour{}}
+	mean := 0.0
+	if total > 0 {
+		mean = totalSum / total
+	}
+	for hour := 0; hour < 24; hour++ {
+		shrunk := (sums[hour] + FocusPriorMinutes*mean) / (minutes[hour] + FocusPriorMinutes)
+		score := round(math.Exp(shrunk - mean))
+		level := FocusNeutral
+		if profile.Learned {
+			switch {
+			case score >= peakScore:
+				level = FocusPeak
+			case score <= troughScore:
+				level = FocusTrough
+			}
+		}
+		profile.Hours = append(profile.Hours, FocusHour{Hour: hour, Minutes: int(minutes[hour]), Score: score, Level: level})
+	}
+	return profile
+}
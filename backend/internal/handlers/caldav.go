@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cal-enderBE/internal/caldav"
+	"cal-enderBE/internal/calendars"
+)
+
+type caldavCredentials struct {
+	ServerURL string `json:"server_url"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+}
+
+func (a *App) ConnectCalDAV(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	if a.Secrets == nil {
+		http.Error(w, "token encryption is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var payload caldavCredentials
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if payload.ServerURL == "" || payload.Username == "" || payload.Password == "" {
+		http.Error(w, "server_url, username and password are required", http.StatusBadRequest)
+		return
+	}
+	client := &caldav.Client{BaseURL: payload.ServerURL, Username: payload.Username, Password: payload.Password, HTTP: calendars.PublicClient()}
+	found, err := client.FindCalendars()
+	if errors.Is(err, caldav.ErrUnauthorized) {
+		http.Error(w, "caldav credentials rejected", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	password, err := a.Secrets.Seal(payload.Password)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, err = a.Supabase.UpsertOn("calendar_connections", "user_id,provider", map[string]any{
+		"user_id":      userID,
+		"provider":     "caldav",
+		"server_url":   payload.ServerURL,
+		"username":     payload.Username,
+		"access_token": password,
+		"updated_at":   time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "connected", "calendars": found})
+}
+
+func (a *App) caldavCredentials(userID string) (caldavCredentials, error) {
+	if a.Secrets == nil {
+		return caldavCredentials{}, errNotConnected
+	}
+	query := url.Values{}
+	query.Set("select", "server_url,username,access_token")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("provider", "eq.caldav")
+	data, err := a.Supabase.Select("calendar_connections", query)
+	if err != nil {
+		return caldavCredentials{}, err
+	}
+	var rows []struct {
+		ServerURL   string `json:"server_url"`
+		Username    string `json:"username"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return caldavCredentials{}, err
+	}
+	if len(rows) == 0 || rows[0].ServerURL == "" || rows[0].AccessToken == "" {
+		return caldavCredentials{}, errNotConnected
+	}
+	password, err := a.Secrets.Open(rows[0].AccessToken)
+	if err != nil {
+		return caldavCredentials{}, err
+	}
+	return caldavCredentials{ServerURL: rows[0].ServerURL, Username: rows[0].Username, Password: password}, nil
+}
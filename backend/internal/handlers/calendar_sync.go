@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/calendars"
+)
+
+type syncStats struct {
+	Imported int  `json:"imported"`
+	Deleted  int  `json:"deleted"`
+	FullSync bool `json:"full_sync"`
+}
+
+func (a *App) syncCalendar(userID, source, calendarID string, provider calendars.Provider, loc *time.Location) (syncStats, error) {
+	syncToken := a.loadSyncToken(userID, source, calendarID)
+	set, err := provider.Changes(calendarID, syncToken)
+	if errors.Is(err, calendars.ErrSyncTokenExpired) && syncToken != "" {
+		set, err = provider.Changes(calendarID, "")
+	}
+	if err != nil {
+		return syncStats{}, err
+	}
+	stats := syncStats{FullSync: set.Complete}
+
+	rows := []map[string]any{}
+	deleted := []string{}
+	seen := map[string]bool{}
+	own := a.ownExternalIDs(userID, source)
+	for _, change := range calendars.Dedupe(set.Changes) {
+		switch {
+		case own[change.ExternalID]:
+		case change.Deleted:
+			deleted = append(deleted, change.ExternalID)
+		case change.Unchanged:
+			seen[change.ExternalID] = true
+		case change.Event != nil:
+			row := eventRow(source, calendarID, *change.Event)
+			row["user_id"] = userID
+			rows = append(rows, row)
+			seen[change.ExternalID] = true
+		}
+	}
+	if len(rows) > 0 {
+		if _, err := a.Supabase.UpsertOn("calendar_events", "user_id,source,calendar_id,external_id", rows); err != nil {
+			return syncStats{}, err
+		}
+	}
+	stats.Imported = len(rows)
+
+	if stats.FullSync {
+		stale, err := a.staleExternalIDs(userID, source, calendarID, seen, time.Now().In(loc).Format("2006-01-02"))
+		if err != nil {
+			return syncStats{}, err
+		}
+		deleted = append(deleted, stale...)
+	}
+	if len(deleted) > 0 {
+		if err := a.deleteExternalEvents(userID, source, calendarID, deleted); err != nil {
+			return syncStats{}, err
+		}
+	}
+	stats.Deleted = len(deleted)
+
+	if set.SyncToken != "" && set.SyncToken != syncToken {
+		if err := a.saveSyncToken(userID, source, calendarID, set.SyncToken); err != nil {
+			return syncStats{}, err
+		}
+	}
+	return stats, nil
+}
+
+func eventRow(source, calendarID string, event calendars.Event) map[string]any {
+	start, end := event.Start, event.End
+	if event.AllDay {
+		end = end.AddDate(0, 0, -1)
+		if end.Before(start) {
+			end = start
+		}
+	}
+	row := map[string]any{
+		"title":       event.Title,
+		"source":      source,
+		"external_id": event.ExternalID,
+		"calendar_id": calendarID,
+		"event_date":  start.Format("2006-01-02"),
+		"end_date":    end.Format("2006-01-02"),
+		"start_time":  start.Format("15:04"),
+		"end_time":    end.Format("15:04"),
+		"all_day":     event.AllDay,
+		"is_fixed":    true,
+	}
+	if event.AllDay {
+		row["start_time"] = "00:00"
+		row["end_time"] = "23:59"
+	}
+	return row
+}
+
+func (a *App) loadSyncToken(userID, provider, calendarID string) string {
+	query := url.Values{}
+	query.Set("select", "sync_token")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("provider", fmt.Sprintf("eq.%s", provider))
+	query.Set("calendar_id", fmt.Sprintf("eq.%s", calendarID))
+	data, err := a.Supabase.Select("calendar_sync", query)
+	if err != nil {
+		return ""
+	}
+	var rows []struct {
+		SyncToken *string `json:"sync_token"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil || len(rows) == 0 || rows[0].SyncToken == nil {
+		return ""
+	}
+	return *rows[0].SyncToken
+}
+
+func (a *App) saveSyncToken(userID, provider, calendarID, syncToken string) error {
+	_, err := a.Supabase.UpsertOn("calendar_sync", "user_id,provider,calendar_id", map[string]any{
+		"user_id":     userID,
+		"provider":    provider,
+		"calendar_id": calendarID,
+		"sync_token":  syncToken,
+		"updated_at":  time.Now().UTC().Format(time.RFC3339),
+	})
+	return err
+}
+
+func (a *App) staleExternalIDs(userID, source, calendarID string, seen map[string]bool, fromDate string) ([]string, error) {
+	query := url.Values{}
+	query.Set("select", "external_id")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("source", fmt.Sprintf("eq.%s", source))
+	query.Set("calendar_id", fmt.Sprintf("eq.%s", calendarID))
+	query.Set("event_date", fmt.Sprintf("gte.%s", fromDate))
+	query.Set("external_id", "not.is.null")
+	data, err := a.Supabase.Select("calendar_events", query)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ExternalID string `json:"external_id"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	stale := []string{}
+	for _, row := range rows {
+		if !seen[row.ExternalID] {
+			stale = append(stale, row.ExternalID)
+		}
+	}
+	return stale, nil
+}
+
+func (a *App) deleteExternalEvents(userID, source, calendarID string, externalIDs []string) error {
+	quoted := make([]string, len(externalIDs))
+	for i, id := range externalIDs {
+		quoted[i] = fmt.Sprintf("%q", id)
+	}
+	filter := fmt.Sprintf("user_id=eq.%s&source=eq.%s&calendar_id=eq.%s&external_id=%s",
+		userID, source, url.QueryEscape(calendarID), url.QueryEscape("in.("+strings.Join(quoted, ",")+")"))
+	return a.Supabase.Delete("calendar_events", filter)
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cal-enderBE/internal/calendars"
+	"cal-enderBE/internal/supabase"
+)
+
+type fakePostgREST struct {
+	mu        sync.Mutex
+	syncToken string
+	events    map[string]map[string]any
+}
+
+func (f *fakePostgREST) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := strings.TrimPrefix(r.URL.Path, "/rest/v1/")
+	query := r.URL.Query()
+	var out any = []any{}
+	switch {
+	case r.Method == "GET" && table == "calendar_sync":
+		if f.syncToken != "" {
+			out = []map[string]string{{"sync_token": f.syncToken}}
+		}
+	case r.Method == "POST" && table == "calendar_sync":
+		var row map[string]any
+		json.NewDecoder(r.Body).Decode(&row)
+		f.syncToken, _ = row["sync_token"].(string)
+		out = []map[string]any{row}
+	case r.Method == "POST" && table == "calendar_events":
+		var rows []map[string]any
+		json.NewDecoder(r.Body).Decode(&rows)
+		for _, row := range rows {
+			f.events[row["external_id"].(string)] = row
+		}
+		out = rows
+	case r.Method == "GET" && table == "calendar_events":
+		from := strings.TrimPrefix(query.Get("event_date"), "gte.")
+		rows := []map[string]any{}
+		for id, row := range f.events {
+			if row["event_date"].(string) >= from {
+				rows = append(rows, map[string]any{"external_id": id})
+			}
+		}
+		out = rows
+	case r.Method == "DELETE" && table == "calendar_events":
+		list := strings.TrimSuffix(strings.TrimPrefix(query.Get("external_id"), "in.("), ")")
+		for _, id := range strings.Split(list, ",") {
+			delete(f.events, strings.Trim(id, `"`))
+		}
+	case r.Method == "GET":
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func (f *fakePostgREST) token() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.syncToken
+}
+
+func (f *fakePostgREST) titles() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	titles := map[string]string{}
+	for id, row := range f.events {
+		titles[id], _ = row["title"].(string)
+	}
+	return titles
+}
+
+type fakeGoogle struct {
+	mu       sync.Mutex
+	full     []googleItem
+	changes  map[string][]googleItem
+	next     map[string]string
+	expired  map[string]bool
+	requests []string
+}
+
+type googleItem struct {
+	ID           string            `json:"id"`
+	Status       string            `json:"status,omitempty"`
+	Summary      string            `json:"summary,omitempty"`
+	Transparency string            `json:"transparency,omitempty"`
+	Start        map[string]string `json:"start,omitempty"`
+	End          map[string]string `json:"end,omitempty"`
+}
+
+func (f *fakeGoogle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path != "/calendars/primary/events" {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.URL.Query().Get("syncToken")
+	f.requests = append(f.requests, token)
+	items := f.full
+	if token != "" {
+		if f.expired[token] {
+			http.Error(w, "sync token is no longer valid", http.StatusGone)
+			return
+		}
+		items = f.changes[token]
+	} else if r.URL.Query().Get("timeMin") == "" {
+		http.Error(w, "full sync without a time window", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"items": items, "nextSyncToken": f.next[token]})
+}
+
+func (f *fakeGoogle) lastToken() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func googleTimed(id, summary string, start time.Time) googleItem {
+	return googleItem{
+		ID:      id,
+		Summary: summary,
+		Start:   map[string]string{"dateTime": start.Format(time.RFC3339)},
+		End:     map[string]string{"dateTime": start.Add(time.Hour).Format(time.RFC3339)},
+	}
+}
+
+func googleFree(id, summary string, start time.Time) googleItem {
+	item := googleTimed(id, summary, start)
+	item.Transparency = "transparent"
+	return item
+}
+
+func TestSyncCalendarGoogleIncremental(t *testing.T) {
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	store := &fakePostgREST{events: map[string]map[string]any{}}
+	database := httptest.NewServer(store)
+	defer database.Close()
+	google := &fakeGoogle{
+		full: []googleItem{
+			googleTimed("a", "Standup", start),
+			googleTimed("b", "Review", start.Add(2*time.Hour)),
+			googleTimed("c", "Retro", start.Add(4*time.Hour)),
+		},
+		changes: map[string][]googleItem{
+			"tok1": {
+				googleTimed("b", "Design review", start.Add(3*time.Hour)),
+				{ID: "c", Status: "cancelled"},
+				googleFree("a", "Standup", start),
+			},
+		},
+		next:    map[string]string{"": "tok1", "tok1": "tok2"},
+		expired: map[string]bool{},
+	}
+	server := httptest.NewServer(google)
+	defer server.Close()
+
+	app := &App{Supabase: supabase.NewClient(database.URL, "service", "anon")}
+	run := func() syncStats {
+		t.Helper()
+		provider, err := calendars.New("google", calendars.Options{BaseURL: server.URL, APIKey: "key", Location: time.Local})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := app.syncCalendar("user-1", "google", "primary", provider, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+
+	stats := run()
+	if !stats.FullSync || stats.Imported != 3 || stats.Deleted != 0 {
+		t.Fatalf("first sync: got %+v, want a full sync importing 3 events", stats)
+	}
+	if store.token() != "tok1" {
+		t.Fatalf("first sync saved token %q, want tok1", store.token())
+	}
+
+	stats = run()
+	if google.lastToken() != "tok1" {
+		t.Fatalf("second sync sent token %q, want tok1", google.lastToken())
+	}
+	if stats.FullSync || stats.Imported != 1 || stats.Deleted != 2 {
+		t.Fatalf("incremental sync: got %+v, want 1 import and 2 deletes", stats)
+	}
+	titles := store.titles()
+	if len(titles) != 1 || titles["b"] != "Design review" {
+		t.Fatalf("after incremental sync: got %v", titles)
+	}
+	if store.token() != "tok2" {
+		t.Fatalf("incremental sync saved token %q, want tok2", store.token())
+	}
+
+	google.mu.Lock()
+	google.expired["tok2"] = true
+	google.full = []googleItem{
+		googleTimed("a", "Standup", start),
+		googleTimed("d", "Planning", start.Add(5*time.Hour)),
+	}
+	google.next[""] = "tok3"
+	google.mu.Unlock()
+
+	stats = run()
+	if google.lastToken() != "" {
+		t.Fatalf("expired token did not fall back to a full sync")
+	}
+	if !stats.FullSync || stats.Imported != 2 || stats.Deleted != 1 {
+		t.Fatalf("resync: got %+v, want a full sync importing 2 and deleting the stale row", stats)
+	}
+	titles = store.titles()
+	if len(titles) != 2 || titles["a"] != "Standup" || titles["d"] != "Planning" {
+		t.Fatalf("after resync: got %v, want the stale row removed", titles)
+	}
+	if store.token() != "tok3" {
+		t.Fatalf("resync saved token %q, want tok3", store.token())
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const laterTodayMinutes = 120
+
+type deferRequest struct {
+	To           string `json:"to"`
+	EarliestDate string `json:"earliest_date"`
+	EarliestTime string `json:"earliest_time"`
+}
+
+func (a *App) DeferTask(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	taskID := chi.URLParam(r, "id")
+	var request deferRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	settings := a.loadSettings(userID)
+	now := time.Now().In(settings.Location)
+	today := now.Format("2006-01-02")
+	nowMinutes := now.Hour()*60 + now.Minute()
+	earliestDate, earliestTime, err := deferTarget(request, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	unlock := a.lockUser(userID)
+	defer unlock()
+
+	taskQuery := url.Values{}
+	taskQuery.Set("select", "*")
+	taskQuery.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	taskQuery.Set("task_date", fmt.Sprintf("gte.%s", today))
+	taskQuery.Set("order", "id.asc")
+	upcoming, err := a.loadTasks(taskQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("id", fmt.Sprintf("eq.%s", taskID))
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	found, err := a.loadTasks(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(found) == 0 {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	task := found[0]
+	if strings.EqualFold(task.Status, "completed") {
+		http.Error(w, "task is already completed", http.StatusBadRequest)
+		return
+	}
+
+	payload := map[string]any{
+		"task_date":     earliestDate,
+		"start_time":    nil,
+		"end_time":      nil,
+		"earliest_date": earliestDate,
+		"earliest_time": nil,
+	}
+	if earliestTime != "" {
+		payload["earliest_time"] = earliestTime
+	}
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", taskID, userID)
+	if _, err := a.Supabase.Update("tasks", filter, payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	// The task is re-placed with its whole estimate, so the continuation
+	// rows still holding the rest of it would double-book that work.
+	released := map[string]bool{}
+	for _, other := range upcoming {
+		if scheduler.IsContinuationOf(other, task) && !strings.EqualFold(other.Status, "completed") {
+			released[other.ID] = true
+		}
+	}
+	if len(released) > 0 {
+		ids := make([]string, 0, len(released))
+		for id := range released {
+			ids = append(ids, id)
+		}
+		filter := fmt.Sprintf("user_id=eq.%s&id=in.(%s)", userID, strings.Join(ids, ","))
+		if err := a.Supabase.Delete("tasks", filter); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		a.removeTaskBlocksFor(userID, ids)
+	}
+	task.TaskDate = earliestDate
+	task.StartTime = nil
+	task.EndTime = nil
+	task.EarliestDate = &earliestDate
+	task.EarliestTime = nil
+	if earliestTime != "" {
+		task.EarliestTime = &earliestTime
+	}
+
+	tasks := []scheduler.Task{task}
+	for _, other := range upcoming {
+		if other.ID != taskID && !released[other.ID] && other.StartTime != nil && other.EndTime != nil {
+			tasks = append(tasks, other)
+		}
+	}
+	events, err := a.loadEvents(userID, today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	settings.DaysOff = a.loadDaysOff(userID, today)
+	settings.StartDate = today
+	settings.StartMinutes = nowMinutes
+	a.applyEstimates(userID, tasks)
+	a.applyFocusProfile(userID, &settings)
+
+	result := scheduler.AutoSchedule(tasks, events, settings, "", false)
+	if err := a.saveScheduleResult(userID, result, settings.Location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	response := map[string]any{
+		"earliest_date":         earliestDate,
+		"earliest_time":         payload["earliest_time"],
+		"placed":                nil,
+		"inserted":              len(result.Inserts),
+		"released":              len(released),
+		"unplaced":              result.Unplaced,
+		"constraint_violations": result.ConstraintViolations,
+	}
+	for _, update := range result.Updates {
+		if update.ID == taskID {
+			response["placed"] = update
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func deferTarget(request deferRequest, now time.Time) (string, string, error) {
+	switch request.To {
+	case "later_today":
+		minutes := now.Hour()*60 + now.Minute() + laterTodayMinutes
+		minutes = (minutes + 14) / 15 * 15
+		if minutes >= 24*60 {
+			return now.AddDate(0, 0, 1).Format("2006-01-02"), "", nil
+		}
+		return now.Format("2006-01-02"), fmt.Sprintf("%02d:%02d", minutes/60, minutes%60), nil
+	case "tomorrow":
+		return now.AddDate(0, 0, 1).Format("2006-01-02"), "", nil
+	case "next_week":
+		days := (8 - int(now.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		return now.AddDate(0, 0, days).Format("2006-01-02"), "", nil
+	case "":
+		if _, err := time.Parse("2006-01-02", request.EarliestDate); err != nil {
+			return "", "", fmt.Errorf("to must be later_today, tomorrow or next_week, or earliest_date must be set")
+		}
+		if request.EarliestDate < now.Format("2006-01-02") {
+			return "", "", fmt.Errorf("earliest_date is in the past")
+		}
+		if request.EarliestTime != "" {
+			if _, err := time.Parse("15:04", request.EarliestTime); err != nil {
+				return "", "", fmt.Errorf("earliest_time must be HH:MM")
+			}
+		}
+		return request.EarliestDate, request.EarliestTime, nil
+	}
+	return "", "", fmt.Errorf("to must be later_today, tomorrow or next_week")
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"cal-enderBE/internal/estimation"
+	"cal-enderBE/internal/scheduler"
+)
+
+const estimateSampleLimit = 200
+
+func (a *App) GetEstimates(w http.ResponseWriter, r *http.Request) {
+	model, err := a.loadEstimateModel(userIDFromContext(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, model)
+}
+
+func (a *App) applyEstimates(userID string, tasks []scheduler.Task) {
+	model, err := a.loadEstimateModel(userID)
+	if err != nil {
+		log.Printf("estimates: user %s: %v", userID, err)
+		return
+	}
+	for i := range tasks {
+		if scheduler.IsContinuation(tasks[i]) {
+			continue
+		}
+		tasks[i].EstimatedHours = model.Adjust(tasks[i].Company, tasks[i].Project, tasks[i].EstimatedHours)
+	}
+}
+
+func (a *App) loadEstimateModel(userID string) (estimation.Model, error) {
+	query := url.Values{}
+	query.Set("select", "actual_minutes,overrun_minutes,tasks(company,project)")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("task_id", "not.is.null")
+	query.Set("actual_minutes", "not.is.null")
+	query.Set("order", "created_at.desc")
+	query.Set("limit", fmt.Sprintf("%d", estimateSampleLimit))
+	data, err := a.Supabase.Select("behavioral_data", query)
+	if err != nil {
+		return estimation.Model{}, err
+	}
+	var rows []struct {
+		ActualMinutes  int `json:"actual_minutes"`
+		OverrunMinutes int `json:"overrun_minutes"`
+		Task           *struct {
+			Company string `json:"company"`
+			Project string `json:"project"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return estimation.Model{}, fmt.Errorf("invalid behavioral data payload")
+	}
+	samples := make([]estimation.Sample, 0, len(rows))
+	for _, row := range rows {
+		sample := estimation.Sample{
+			EstimatedMinutes: row.ActualMinutes - row.OverrunMinutes,
+			ActualMinutes:    row.ActualMinutes,
+		}
+		if row.Task != nil {
+			sample.Company = row.Task.Company
+			sample.Project = row.Task.Project
+		}
+		samples = append(samples, sample)
+	}
+	return estimation.Fit(samples), nil
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/ical"
+	"cal-enderBE/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const feedPastDays = 30
+
+type feedOptions struct {
+	IncludeBreaks bool `json:"include_breaks"`
+	IncludeEvents bool `json:"include_events"`
+}
+
+type feedBlock struct {
+	date  string
+	start int
+	end   int
+	task  bool
+}
+
+func (a *App) GetFeed(w http.ResponseWriter, r *http.Request) {
+	owner, options, err := a.loadFeed("user_id", userIDFromContext(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled":        owner != "",
+		"include_breaks": options.IncludeBreaks,
+		"include_events": options.IncludeEvents,
+	})
+}
+
+func (a *App) CreateFeed(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	options := feedOptions{IncludeEvents: true}
+	json.NewDecoder(r.Body).Decode(&options)
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(buf)
+	_, err := a.Supabase.UpsertOn("calendar_feeds", "user_id", map[string]any{
+		"user_id":        userID,
+		"token_hash":     feedTokenHash(token),
+		"include_breaks": options.IncludeBreaks,
+		"include_events": options.IncludeEvents,
+		"created_at":     time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	scheme := "https"
+	if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") != "https" {
+		scheme = "http"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"url":            fmt.Sprintf("%s://%s/api/feed/%s.ics", scheme, r.Host, token),
+		"include_breaks": options.IncludeBreaks,
+		"include_events": options.IncludeEvents,
+	})
+}
+
+func (a *App) UpdateFeed(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	updates := map[string]any{}
+	for _, key := range []string{"include_breaks", "include_events"} {
+		if value, ok := payload[key].(bool); ok {
+			updates[key] = value
+		}
+	}
+	filter := fmt.Sprintf("user_id=eq.%s", userID)
+	response, err := a.Supabase.Update("calendar_feeds", filter, updates)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Write(response)
+}
+
+func (a *App) DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	filter := fmt.Sprintf("user_id=eq.%s", userIDFromContext(r))
+	if err := a.Supabase.Delete("calendar_feeds", filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (a *App) ServeFeed(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if len(token) != 64 {
+		http.NotFound(w, r)
+		return
+	}
+	userID, options, err := a.loadFeed("token_hash", feedTokenHash(token))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if userID == "" {
+		http.NotFound(w, r)
+		return
+	}
+	settings := a.loadSettings(userID)
+	loc := settings.Location
+	from := time.Now().In(loc).AddDate(0, 0, -feedPastDays).Format("2006-01-02")
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("task_date", fmt.Sprintf("gte.%s", from))
+	query.Set("start_time", "not.is.null")
+	query.Set("end_time", "not.is.null")
+	tasks, err := a.loadTasks(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	events := []scheduler.Event{}
+	if options.IncludeEvents || options.IncludeBreaks {
+		events, err = a.loadEvents(userID, from)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	feed := feedEvents(tasks, events, options, settings.BreakMinutes, loc)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write([]byte(ical.Marshal("cal-ender schedule", feed)))
+}
+
+func feedEvents(tasks []scheduler.Task, events []scheduler.Event, options feedOptions, breakMinutes int, loc *time.Location) []ical.Event {
+	out := []ical.Event{}
+	blocks := []feedBlock{}
+	for _, task := range tasks {
+		if task.StartTime == nil || task.EndTime == nil {
+			continue
+		}
+		start, end := scheduler.ToMinutes(*task.StartTime), scheduler.ToMinutes(*task.EndTime)
+		out = append(out, ical.Event{
+			UID:     fmt.Sprintf("task-%s@cal-ender", task.ID),
+			Summary: task.Title,
+			Start:   feedTime(task.TaskDate, start, loc),
+			End:     feedTime(task.TaskDate, end, loc),
+		})
+		blocks = append(blocks, feedBlock{date: task.TaskDate, start: start, end: end, task: true})
+	}
+	for _, event := range events {
+		if options.IncludeEvents {
+			out = append(out, feedEvent(event, loc))
+		}
+		if !event.AllDay && (event.EndDate == nil || *event.EndDate == event.EventDate) {
+			blocks = append(blocks, feedBlock{date: event.EventDate, start: scheduler.ToMinutes(event.StartTime), end: scheduler.ToMinutes(event.EndTime)})
+		}
+	}
+	if options.IncludeBreaks && breakMinutes > 0 {
+		out = append(out, feedBreaks(blocks, breakMinutes, loc)...)
+	}
+	return out
+}
+
+func feedEvent(event scheduler.Event, loc *time.Location) ical.Event {
+	endDate := event.EventDate
+	if event.EndDate != nil && *event.EndDate != "" {
+		endDate = *event.EndDate
+	}
+	out := ical.Event{UID: fmt.Sprintf("event-%s@cal-ender", event.ID), Summary: event.Title, AllDay: event.AllDay}
+	if event.AllDay {
+		out.Start = feedTime(event.EventDate, 0, loc)
+		out.End = feedTime(endDate, 0, loc).AddDate(0, 0, 1)
+		return out
+	}
+	start, end := scheduler.ToMinutes(event.StartTime), scheduler.ToMinutes(event.EndTime)
+	out.Start = feedTime(event.EventDate, start, loc)
+	out.End = feedTime(endDate, end, loc)
+	if endDate == event.EventDate && end < start {
+		out.End = out.End.AddDate(0, 0, 1)
+	}
+	return out
+}
+
+func feedBreaks(blocks []feedBlock, breakMinutes int, loc *time.Location) []ical.Event {
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].date != blocks[j].date {
+			return blocks[i].date < blocks[j].date
+		}
+		return blocks[i].start < blocks[j].start
+	})
+	out := []ical.Event{}
+	for i := 0; i+1 < len(blocks); i++ {
+		current, next := blocks[i], blocks[i+1]
+		if !current.task || current.date != next.date || current.end+breakMinutes > next.start {
+			continue
+		}
+		out = append(out, ical.Event{
+			UID:     fmt.Sprintf("break-%s-%d@cal-ender", strings.ReplaceAll(current.date, "-", ""), current.end),
+			Summary: "Break",
+			Start:   feedTime(current.date, current.end, loc),
+			End:     feedTime(current.date, current.end+breakMinutes, loc),
+			Props:   []ical.Property{{Name: "TRANSP", Value: "TRANSPARENT"}},
+		})
+	}
+	return out
+}
+
+func feedTime(date string, minutes int, loc *time.Location) time.Time {
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, minutes, 0, 0, loc)
+}
+
+func feedTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *App) loadFeed(column, value string) (string, feedOptions, error) {
+	query := url.Values{}
+	query.Set("select", "user_id,include_breaks,include_events")
+	query.Set(column, fmt.Sprintf("eq.%s", value))
+	data, err := a.Supabase.Select("calendar_feeds", query)
+	if err != nil {
+		return "", feedOptions{}, err
+	}
+	var rows []struct {
+		UserID string `json:"user_id"`
+		feedOptions
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return "", feedOptions{}, err
+	}
+	if len(rows) == 0 {
+		return "", feedOptions{}, nil
+	}
+	return rows[0].UserID, rows[0].feedOptions, nil
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"cal-enderBE/internal/estimation"
+	"cal-enderBE/internal/scheduler"
+)
+
+func (a *App) GetFocusProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := a.loadFocusProfile(userIDFromContext(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+func (a *App) applyFocusProfile(userID string, settings *scheduler.Settings) {
+	if !settings.FocusMatching {
+		return
+	}
+	profile, err := a.loadFocusProfile(userID)
+	if err != nil {
+		log.Printf("focus: user %s: %v", userID, err)
+		return
+	}
+	for _, hour := range profile.Hours {
+		switch hour.Level {
+		case estimation.FocusPeak:
+			settings.FocusLevels[hour.Hour] = 1
+		case estimation.FocusTrough:
+			settings.FocusLevels[hour.Hour] = -1
+		}
+	}
+}
+
+func (a *App) loadFocusProfile(userID string) (estimation.FocusProfile, error) {
+	query := url.Values{}
+	query.Set("select", "start_time,end_time,actual_minutes,overrun_minutes")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("task_id", "not.is.null")
+	query.Set("actual_minutes", "not.is.null")
+	query.Set("start_time", "not.is.null")
+	query.Set("end_time", "not.is.null")
+	query.Set("order", "created_at.desc")
+	query.Set("limit", fmt.Sprintf("%d", estimateSampleLimit))
+	data, err := a.Supabase.Select("behavioral_data", query)
+	if err != nil {
+		return estimation.FocusProfile{}, err
+	}
+	var rows []struct {
+		StartTime      string `json:"start_time"`
+		EndTime        string `json:"end_time"`
+		ActualMinutes  int    `json:"actual_minutes"`
+		OverrunMinutes int    `json:"overrun_minutes"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return estimation.FocusProfile{}, fmt.Errorf("invalid behavioral data payload")
+	}
+	samples := make([]estimation.FocusSample, 0, len(rows))
+	for _, row := range rows {
+		samples = append(samples, estimation.FocusSample{
+			Start:            scheduler.ToMinutes(row.StartTime),
+			End:              scheduler.ToMinutes(row.EndTime),
+			ActualMinutes:    row.ActualMinutes,
+			EstimatedMinutes: row.ActualMinutes - row.OverrunMinutes,
+		})
+	}
+	return estimation.FitFocus(samples), nil
+}
@@ -4,19 +4,30 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"cal-enderBE/internal/scheduler"
+	"cal-enderBE/internal/secretbox"
 	"cal-enderBE/internal/supabase"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type App struct {
-	Supabase *supabase.Client
+	Supabase          *supabase.Client
+	Secrets           *secretbox.Box
+	GoogleCalendarURL string
+	GraphURL          string
+	OAuth             map[string]OAuthConfig
+	scheduleLocks     sync.Map
+	writebackLocks    sync.Map
+	writebackQueues   sync.Map
 }
 
 type contextKey string
@@ -125,30 +136,28 @@ func (a *App) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	payload["user_id"] = userID
+	completing, _ := payload["status"].(string)
+	var timed []scheduler.Task
+	if completing == "completed" {
+		unlock := a.lockUser(userID)
+		defer unlock()
+		taskQuery := url.Values{}
+		taskQuery.Set("select", "*")
+		taskQuery.Set("id", fmt.Sprintf("eq.%s", taskID))
+		taskQuery.Set("user_id", fmt.Sprintf("eq.%s", userID))
+		timed, _ = a.loadTasks(taskQuery)
+	}
 	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", taskID, userID)
 	response, err := a.Supabase.Update("tasks", filter, payload)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
-	if status, ok := payload["status"].(string); ok && status == "completed" {
-		taskQuery := url.Values{}
-		taskQuery.Set("select", "actual_start,end_time")
-		taskQuery.Set("id", fmt.Sprintf("eq.%s", taskID))
-		taskQuery.Set("user_id", fmt.Sprintf("eq.%s", userID))
-		taskData, err := a.Supabase.Select("tasks", taskQuery)
-		if err == nil {
-			var rows []map[string]any
-			json.Unmarshal(taskData, &rows)
-			if len(rows) > 0 {
-				now := time.Now().Format("15:04")
-				_, _ = a.Supabase.Insert("behavioral_data", map[string]any{
-					"user_id":         userID,
-					"task_id":         taskID,
-					"start_time":      rows[0]["actual_start"],
-					"end_time":        now,
-					"overrun_minutes": 0,
-				})
+	if completing == "completed" {
+		a.removeTaskBlocksFor(userID, []string{taskID})
+		if len(timed) > 0 && !strings.EqualFold(timed[0].Status, "completed") {
+			if _, err := a.finishTimer(userID, timed[0], time.Now(), a.userLocation(userID)); err != nil {
+				log.Printf("timer: user %s: %v", userID, err)
 			}
 		}
 	}
@@ -163,6 +172,7 @@ func (a *App) DeleteTask(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
+	a.removeTaskBlocksFor(userID, []string{taskID})
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
@@ -201,14 +211,25 @@ func (a *App) GetEvents(w http.ResponseWriter, r *http.Request) {
 	query := url.Values{}
 	query.Set("select", "*")
 	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("recurrence", "is.null")
+	query.Set("recurring_event_id", "is.null")
+	from := time.Now().In(a.userLocation(userID)).Format("2006-01-02")
+	to := ""
 	if date := r.URL.Query().Get("date"); date != "" {
-		query.Set("event_date", fmt.Sprintf("eq.%s", date))
+		query.Set("event_date", fmt.Sprintf("lte.%s", date))
+		query.Set("or", fmt.Sprintf("(event_date.eq.%s,end_date.gte.%s)", date, date))
+		from, to = date, date
 	}
 	if start := r.URL.Query().Get("start"); start != "" {
-		query.Set("event_date", fmt.Sprintf("gte.%s", start))
+		query.Set("or", fmt.Sprintf("(event_date.gte.%s,end_date.gte.%s)", start, start))
+		from = start
 	}
 	if end := r.URL.Query().Get("end"); end != "" {
 		query.Set("event_date", fmt.Sprintf("lte.%s", end))
+		to = end
+	}
+	if to == "" {
+		to = addDate(from, scheduler.HorizonDays)
 	}
 	query.Set("order", "event_date.asc,start_time.asc")
 	response, err := a.Supabase.Select("calendar_events", query)
@@ -216,7 +237,19 @@ func (a *App) GetEvents(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
-	w.Write(response)
+	var rows []map[string]any
+	if err := json.Unmarshal(response, &rows); err != nil {
+		http.Error(w, "invalid events payload", http.StatusBadGateway)
+		return
+	}
+	recurring, err := a.recurringEvents(userID, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	rows = append(rows, recurring...)
+	sortEventRows(rows)
+	writeJSON(w, http.StatusOK, rows)
 }
 
 func (a *App) CreateEvent(w http.ResponseWriter, r *http.Request) {
@@ -227,6 +260,10 @@ func (a *App) CreateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	payload["user_id"] = userID
+	if err := normalizeRecurrence(payload); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	response, err := a.Supabase.Insert("calendar_events", payload)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadGateway)
@@ -268,59 +305,148 @@ type scheduleRequest struct {
 	FocusKey       string `json:"focus_key"`
 	AllowReshuffle bool   `json:"allow_reshuffle"`
 	StartDay       string `json:"start_day"`
+	DryRun         bool   `json:"dry_run"`
+	Solver         string `json:"solver"`
 }
 
 func (a *App) AutoSchedule(w http.ResponseWriter, r *http.Request) {
 	userID := userIDFromContext(r)
 	var request scheduleRequest
 	json.NewDecoder(r.Body).Decode(&request)
+	settings := a.loadSettings(userID)
 	if request.StartDay == "" {
-		request.StartDay = time.Now().Format("2006-01-02")
+		request.StartDay = time.Now().In(settings.Location).Format("2006-01-02")
 	}
 
+	unlock := a.lockUser(userID)
+	defer unlock()
+
+	// A preview schedules pending template instances in memory; only a
+	// real save writes them.
+	if !request.DryRun {
+		if _, err := a.materializeTemplates(userID, settings.Location); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	result, fingerprint, err := a.planSchedule(userID, request, settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if request.DryRun {
+		plan, err := a.storePlan(userID, request, result, fingerprint)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"plan_id": plan.ID, "expires_at": plan.ExpiresAt, "result": result})
+		return
+	}
+	if err := a.saveScheduleResult(userID, result, settings.Location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updated":               len(result.Updates),
+		"inserted":              len(result.Inserts),
+		"unplaced":              result.Unplaced,
+		"deadline_violations":   result.DeadlineViolations,
+		"finishes":              result.Finishes,
+		"constraint_violations": result.ConstraintViolations,
+	})
+}
+
+func (a *App) planSchedule(userID string, request scheduleRequest, settings scheduler.Settings) (scheduler.ScheduleResult, string, error) {
 	taskQuery := url.Values{}
 	taskQuery.Set("select", "*")
 	taskQuery.Set("user_id", fmt.Sprintf("eq.%s", userID))
 	taskQuery.Set("task_date", fmt.Sprintf("gte.%s", request.StartDay))
-	taskData, err := a.Supabase.Select("tasks", taskQuery)
+	taskQuery.Set("order", "id.asc")
+	tasks, err := a.loadTasks(taskQuery)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
-		return
+		return scheduler.ScheduleResult{}, "", err
+	}
+	pending, err := a.pendingTemplateTasks(userID, settings.Location)
+	if err != nil {
+		return scheduler.ScheduleResult{}, "", err
+	}
+	for _, task := range pending {
+		if task.TaskDate >= request.StartDay {
+			tasks = append(tasks, task)
+		}
+	}
+	events, err := a.loadEvents(userID, request.StartDay)
+	if err != nil {
+		return scheduler.ScheduleResult{}, "", err
+	}
+	settings.DaysOff = a.loadDaysOff(userID, request.StartDay)
+	a.applyEstimates(userID, tasks)
+	a.applyFocusProfile(userID, &settings)
+
+	fingerprint := scheduleFingerprint(tasks, events, settings)
+	result := scheduler.Schedule(request.Solver, tasks, events, settings, request.FocusKey, request.AllowReshuffle)
+	return result, fingerprint, nil
+}
+
+func (a *App) lockUser(userID string) func() {
+	value, _ := a.scheduleLocks.LoadOrStore(userID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (a *App) loadTasks(query url.Values) ([]scheduler.Task, error) {
+	data, err := a.Supabase.Select("tasks", query)
+	if err != nil {
+		return nil, err
 	}
 	var tasks []scheduler.Task
-	if err := json.Unmarshal(taskData, &tasks); err != nil {
-		http.Error(w, "invalid tasks payload", http.StatusBadGateway)
-		return
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, fmt.Errorf("invalid tasks payload")
 	}
+	return tasks, nil
+}
 
-	eventQuery := url.Values{}
-	eventQuery.Set("select", "*")
-	eventQuery.Set("user_id", fmt.Sprintf("eq.%s", userID))
-	eventQuery.Set("event_date", fmt.Sprintf("gte.%s", request.StartDay))
-	eventData, err := a.Supabase.Select("calendar_events", eventQuery)
+func (a *App) loadEvents(userID, startDay string) ([]scheduler.Event, error) {
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("or", fmt.Sprintf("(event_date.gte.%s,end_date.gte.%s)", startDay, startDay))
+	query.Set("recurrence", "is.null")
+	query.Set("recurring_event_id", "is.null")
+	query.Set("order", "id.asc")
+	data, err := a.Supabase.Select("calendar_events", query)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
-		return
+		return nil, err
 	}
-	var events []scheduler.Event
-	if err := json.Unmarshal(eventData, &events); err != nil {
-		http.Error(w, "invalid events payload", http.StatusBadGateway)
-		return
+	var rows []map[string]any
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("invalid events payload")
 	}
+	recurring, err := a.recurringEvents(userID, startDay, addDate(startDay, scheduler.HorizonDays))
+	if err != nil {
+		return nil, err
+	}
+	return eventsFromRows(append(rows, recurring...))
+}
 
+func (a *App) loadSettings(userID string) scheduler.Settings {
 	settings := scheduler.Settings{
 		WorkStartMinutes: 540,
 		WorkEndMinutes:   1020,
 		BreakMinutes:     15,
+		Location:         time.UTC,
+		AllDayPolicy:     scheduler.AllDayInformational,
 	}
-	settingsQuery := url.Values{}
-	settingsQuery.Set("select", "*")
-	settingsQuery.Set("user_id", fmt.Sprintf("eq.%s", userID))
-	settingsData, _ := a.Supabase.Select("user_settings", settingsQuery)
-	var settingsRows []map[string]any
-	json.Unmarshal(settingsData, &settingsRows)
-	if len(settingsRows) > 0 {
-		row := settingsRows[0]
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	data, _ := a.Supabase.Select("user_settings", query)
+	var rows []map[string]any
+	json.Unmarshal(data, &rows)
+	if len(rows) > 0 {
+		row := rows[0]
 		if workStart, ok := row["work_start"].(string); ok {
 			settings.WorkStartMinutes = scheduler.ToMinutes(workStart)
 		}
@@ -330,161 +456,112 @@ func (a *App) AutoSchedule(w http.ResponseWriter, r *http.Request) {
 		if breakLen, ok := row["break_length"].(float64); ok {
 			settings.BreakMinutes = int(breakLen)
 		}
-	}
-
-	behaviorOverrun := a.getBehaviorOverrunMinutes(userID)
-	if behaviorOverrun > 0 {
-		for i := range tasks {
-			if tasks[i].EstimatedHours > 0 {
-				tasks[i].EstimatedHours += float64(behaviorOverrun) / 60
-			}
+		if workHours, ok := row["work_hours"].(map[string]any); ok && len(workHours) > 0 {
+			settings.Availability = parseWorkHours(workHours)
 		}
-	}
-	result := scheduler.AutoSchedule(tasks, events, settings, request.FocusKey, request.AllowReshuffle)
-	for _, update := range result.Updates {
-		filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", update.ID, userID)
-		payload := map[string]any{
-			"task_date":  update.TaskDate,
-			"start_time": update.StartTime,
-			"end_time":   update.EndTime,
+		if policy, ok := row["all_day_policy"].(string); ok && policy != "" {
+			settings.AllDayPolicy = policy
 		}
-		if _, err := a.Supabase.Update("tasks", filter, payload); err != nil {
-			http.Error(w, err.Error(), http.StatusBadGateway)
-			return
+		if policies, ok := row["calendar_all_day_policies"].(map[string]any); ok {
+			settings.CalendarPolicies = map[string]string{}
+			for calendarID, value := range policies {
+				if policy, ok := value.(string); ok {
+					settings.CalendarPolicies[calendarID] = policy
+				}
+			}
 		}
-	}
-	if len(result.Inserts) > 0 {
-		if _, err := a.Supabase.Insert("tasks", result.Inserts); err != nil {
-			http.Error(w, err.Error(), http.StatusBadGateway)
-			return
+		if timezone, ok := row["timezone"].(string); ok && timezone != "" {
+			if loc, err := time.LoadLocation(timezone); err == nil {
+				settings.Location = loc
+			}
+		}
+		if focusMatching, ok := row["focus_matching"].(bool); ok {
+			settings.FocusMatching = focusMatching
 		}
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"updated": len(result.Updates), "inserted": len(result.Inserts)})
+	return settings
 }
 
-func (a *App) getBehaviorOverrunMinutes(userID string) int {
-	query := url.Values{}
-	query.Set("select", "overrun_minutes")
-	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
-	query.Set("order", "created_at.desc")
-	query.Set("limit", "20")
-	data, err := a.Supabase.Select("behavioral_data", query)
-	if err != nil {
-		return 0
-	}
-	var rows []map[string]any
-	if err := json.Unmarshal(data, &rows); err != nil {
-		return 0
-	}
-	if len(rows) == 0 {
-		return 0
-	}
-	total := 0
-	for _, row := range rows {
-		if value, ok := row["overrun_minutes"].(float64); ok {
-			total += int(value)
-		}
-	}
-	return total / len(rows)
+func (a *App) userLocation(userID string) *time.Location {
+	return a.loadSettings(userID).Location
 }
 
-func (a *App) ImportGoogleCalendar(w http.ResponseWriter, r *http.Request) {
-	userID := userIDFromContext(r)
-	var payload struct {
-		APIKey     string `json:"api_key"`
-		CalendarID string `json:"calendar_id"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		http.Error(w, "invalid payload", http.StatusBadRequest)
-		return
-	}
-	if payload.APIKey == "" || payload.CalendarID == "" {
-		http.Error(w, "missing api key or calendar id", http.StatusBadRequest)
-		return
-	}
-	events, err := fetchGoogleEvents(payload.APIKey, payload.CalendarID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
-		return
-	}
-	for i := range events {
-		events[i]["user_id"] = userID
-	}
-	if _, err := a.Supabase.Insert("calendar_events", events); err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
-		return
-	}
-	writeJSON(w, http.StatusOK, map[string]any{"imported": len(events)})
+var weekdayKeys = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
 }
 
-func fetchGoogleEvents(apiKey, calendarID string) ([]map[string]any, error) {
-	timeMin := url.QueryEscape(time.Now().Format(time.RFC3339))
-	timeMax := url.QueryEscape(time.Now().Add(30 * 24 * time.Hour).Format(time.RFC3339))
-	endpoint := fmt.Sprintf("https://www.googleapis.com/calendar/v3/calendars/%s/events?key=%s&timeMin=%s&timeMax=%s&singleEvents=true&orderBy=startTime",
-		url.PathEscape(calendarID),
-		url.QueryEscape(apiKey),
-		timeMin,
-		timeMax,
-	)
-	resp, err := http.Get(endpoint)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("google api error: %s", resp.Status)
-	}
-	var payload struct {
-		Items []struct {
-			Summary string `json:"summary"`
-			Start   struct {
-				DateTime string `json:"dateTime"`
-				Date     string `json:"date"`
-			} `json:"start"`
-			End struct {
-				DateTime string `json:"dateTime"`
-				Date     string `json:"date"`
-			} `json:"end"`
-		} `json:"items"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, err
-	}
-	events := []map[string]any{}
-	for _, item := range payload.Items {
-		start := item.Start.DateTime
-		end := item.End.DateTime
-		if start == "" {
-			start = item.Start.Date
-		}
-		if end == "" {
-			end = item.End.Date
+func parseWorkHours(workHours map[string]any) map[time.Weekday][][2]int {
+	availability := map[time.Weekday][][2]int{}
+	for key, value := range workHours {
+		weekday, ok := weekdayKeys[strings.ToLower(key)]
+		if !ok {
+			continue
 		}
-		if start == "" || end == "" {
+		windows, ok := value.([]any)
+		if !ok {
 			continue
 		}
-		startDate := parseDate(start)
-		endDate := parseDate(end)
-		event := map[string]any{
-			"title":      item.Summary,
-			"source":     "google",
-			"event_date": startDate.Format("2006-01-02"),
-			"start_time": startDate.Format("15:04"),
-			"end_time":   endDate.Format("15:04"),
-			"is_fixed":   true,
+		for _, item := range windows {
+			window, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			start, _ := window["start"].(string)
+			end, _ := window["end"].(string)
+			if start == "" || end == "" || scheduler.ToMinutes(end) <= scheduler.ToMinutes(start) {
+				continue
+			}
+			availability[weekday] = append(availability[weekday], [2]int{scheduler.ToMinutes(start), scheduler.ToMinutes(end)})
+		}
+	}
+	if len(availability) == 0 {
+		return nil
+	}
+	for weekday, windows := range availability {
+		sort.Slice(windows, func(i, j int) bool {
+			return windows[i][0] < windows[j][0]
+		})
+		merged := [][2]int{windows[0]}
+		for _, window := range windows[1:] {
+			last := &merged[len(merged)-1]
+			if window[0] <= last[1] {
+				if window[1] > last[1] {
+					last[1] = window[1]
+				}
+				continue
+			}
+			merged = append(merged, window)
 		}
-		events = append(events, event)
+		availability[weekday] = merged
 	}
-	return events, nil
+	return availability
 }
 
-func parseDate(value string) time.Time {
-	if strings.Contains(value, "T") {
-		t, _ := time.Parse(time.RFC3339, value)
-		return t
+func (a *App) saveScheduleResult(userID string, result scheduler.ScheduleResult, loc *time.Location) error {
+	for _, update := range result.Updates {
+		filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", update.ID, userID)
+		payload := map[string]any{
+			"task_date":  update.TaskDate,
+			"start_time": update.StartTime,
+			"end_time":   update.EndTime,
+		}
+		if _, err := a.Supabase.Update("tasks", filter, payload); err != nil {
+			return err
+		}
+	}
+	if len(result.Inserts) > 0 {
+		if _, err := a.Supabase.Insert("tasks", result.Inserts); err != nil {
+			return err
+		}
 	}
-	t, _ := time.Parse("2006-01-02", value)
-	return t
+	a.writeBackAfterSave(userID, loc)
+	return nil
 }
 
 func (a *App) AIBreakdown(w http.ResponseWriter, r *http.Request) {
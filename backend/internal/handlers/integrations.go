@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"cal-enderBE/internal/calendars"
+	"cal-enderBE/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxICSUpload = 10 << 20
+
+func (a *App) GetCalendars(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	provider, err := a.calendarProvider(userIDFromContext(r), name, "", nil)
+	if err != nil {
+		calendarError(w, name, err)
+		return
+	}
+	list, err := provider.ListCalendars()
+	if err != nil {
+		calendarError(w, name, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (a *App) ImportCalendar(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	name := chi.URLParam(r, "provider")
+	var payload struct {
+		APIKey     string `json:"api_key"`
+		CalendarID string `json:"calendar_id"`
+	}
+	json.NewDecoder(r.Body).Decode(&payload)
+	if payload.CalendarID == "" {
+		payload.CalendarID = "primary"
+	}
+	loc := a.userLocation(userID)
+	provider, err := a.calendarProvider(userID, name, payload.APIKey, loc)
+	if err != nil {
+		calendarError(w, name, err)
+		return
+	}
+	stats, err := a.syncCalendar(userID, name, payload.CalendarID, provider, loc)
+	if err != nil {
+		calendarError(w, name, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (a *App) UploadICS(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxICSUpload)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing ics file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "ics file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	name := r.FormValue("name")
+	if name == "" {
+		name = header.Filename
+	}
+	loc := a.userLocation(userID)
+	provider, err := calendars.New("ics", calendars.Options{
+		Data:     string(data),
+		Days:     scheduler.HorizonDays,
+		Location: loc,
+	})
+	if err != nil {
+		calendarError(w, "ics", err)
+		return
+	}
+	stats, err := a.syncCalendar(userID, "ics", "upload:"+name, provider, loc)
+	if err != nil {
+		calendarError(w, "ics", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (a *App) calendarProvider(userID, name, apiKey string, loc *time.Location) (calendars.Provider, error) {
+	options := calendars.Options{APIKey: apiKey, Location: loc}
+	switch name {
+	case "google":
+		options.BaseURL = a.GoogleCalendarURL
+	case "ms365":
+		options.BaseURL = a.GraphURL
+	case "ics":
+		options.Days = scheduler.HorizonDays
+	}
+	if name == "caldav" {
+		credentials, err := a.caldavCredentials(userID)
+		if err != nil {
+			return nil, err
+		}
+		options.BaseURL = credentials.ServerURL
+		options.Username = credentials.Username
+		options.Password = credentials.Password
+	} else if _, ok := oauthProviders[name]; ok && apiKey == "" {
+		accessToken, err := a.accessToken(userID, name)
+		if err != nil {
+			return nil, err
+		}
+		options.AccessToken = accessToken
+	}
+	return calendars.New(name, options)
+}
+
+func calendarError(w http.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, errNotConnected):
+		http.Error(w, name+" account not connected", http.StatusBadRequest)
+	case errors.Is(err, calendars.ErrUnknownProvider):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, calendars.ErrUnauthorized):
+		http.Error(w, name+" rejected the stored credentials", http.StatusBadRequest)
+	case errors.Is(err, calendars.ErrNotFound):
+		http.Error(w, "calendar not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/calendars"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	oauthStateTTL   = 10 * time.Minute
+	tokenRefreshGap = time.Minute
+)
+
+var errNotConnected = errors.New("calendar account not connected")
+
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	AuthURL      string
+	TokenURL     string
+	SuccessURL   string
+}
+
+type oauthProvider struct {
+	authURL    string
+	tokenURL   string
+	scope      string
+	authParams map[string]string
+}
+
+var oauthProviders = map[string]oauthProvider{
+	"google": {
+		authURL:    "https://accounts.google.com/o/oauth2/v2/auth",
+		tokenURL:   "https://oauth2.googleapis.com/token",
+		scope:      "https://www.googleapis.com/auth/calendar.readonly https://www.googleapis.com/auth/calendar.events",
+		authParams: map[string]string{"access_type": "offline", "prompt": "consent"},
+	},
+	"ms365": {
+		authURL:    "https://login.microsoftonline.com/common/oauth2/v2.0/authorize",
+		tokenURL:   "https://login.microsoftonline.com/common/oauth2/v2.0/token",
+		scope:      "offline_access Calendars.ReadWrite",
+		authParams: map[string]string{"response_mode": "query"},
+	},
+}
+
+type oauthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+}
+
+func (a *App) oauthConfig(provider string) (OAuthConfig, oauthProvider, bool) {
+	defaults, ok := oauthProviders[provider]
+	if !ok || a.Secrets == nil {
+		return OAuthConfig{}, oauthProvider{}, false
+	}
+	config := a.OAuth[provider]
+	if config.ClientID == "" || config.ClientSecret == "" || config.RedirectURL == "" {
+		return OAuthConfig{}, oauthProvider{}, false
+	}
+	if config.AuthURL != "" {
+		defaults.authURL = config.AuthURL
+	}
+	if config.TokenURL != "" {
+		defaults.tokenURL = config.TokenURL
+	}
+	return config, defaults, true
+}
+
+func (a *App) ConnectCalendar(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	provider := chi.URLParam(r, "provider")
+	config, endpoints, ok := a.oauthConfig(provider)
+	if !ok {
+		http.Error(w, provider+" oauth is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	expiry := time.Now().UTC().Add(oauthStateTTL)
+	state, err := a.Secrets.Seal(fmt.Sprintf("%s|%s|%d|%s", userID, provider, expiry.Unix(), hex.EncodeToString(nonce)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := a.storeOAuthState(userID, provider, hex.EncodeToString(nonce), expiry); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	query := url.Values{}
+	query.Set("client_id", config.ClientID)
+	query.Set("redirect_uri", config.RedirectURL)
+	query.Set("response_type", "code")
+	query.Set("scope", endpoints.scope)
+	query.Set("state", state)
+	for key, value := range endpoints.authParams {
+		query.Set(key, value)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"url": endpoints.authURL + "?" + query.Encode()})
+}
+
+func (a *App) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	provider := chi.URLParam(r, "provider")
+	config, _, ok := a.oauthConfig(provider)
+	if !ok {
+		http.Error(w, provider+" oauth is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if oauthErr := r.URL.Query().Get("error"); oauthErr != "" {
+		http.Error(w, provider+" authorization failed: "+oauthErr, http.StatusBadRequest)
+		return
+	}
+	userID, nonce, err := a.parseOAuthState(r.URL.Query().Get("state"), provider)
+	if err != nil {
+		http.Error(w, "invalid state", http.StatusBadRequest)
+		return
+	}
+	if err := a.consumeOAuthState(userID, provider, nonce); err != nil {
+		http.Error(w, "invalid state", http.StatusBadRequest)
+		return
+	}
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "missing code", http.StatusBadRequest)
+		return
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", config.RedirectURL)
+	token, err := a.requestToken(provider, form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if token.RefreshToken == "" {
+		http.Error(w, provider+" did not return a refresh token", http.StatusBadGateway)
+		return
+	}
+	if err := a.saveConnection(userID, provider, token); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if config.SuccessURL != "" {
+		http.Redirect(w, r, config.SuccessURL, http.StatusFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "connected"})
+}
+
+func (a *App) DisconnectCalendar(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	provider := chi.URLParam(r, "provider")
+	filter := fmt.Sprintf("user_id=eq.%s&provider=eq.%s", userID, provider)
+	if err := a.Supabase.Delete("calendar_connections", filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "disconnected"})
+}
+
+func (a *App) parseOAuthState(state, provider string) (string, string, error) {
+	opened, err := a.Secrets.Open(state)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.SplitN(opened, "|", 4)
+	if len(parts) != 4 || parts[0] == "" || parts[1] != provider || parts[3] == "" {
+		return "", "", errors.New("malformed state")
+	}
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return "", "", errors.New("expired state")
+	}
+	return parts[0], parts[3], nil
+}
+
+// storeOAuthState records the nonce sealed into a connect URL. The callback
+// arrives as a cross-site redirect without the app's credentials, so the
+// server keeps the nonce instead of the browser.
+func (a *App) storeOAuthState(userID, provider, nonce string, expiry time.Time) error {
+	filter := fmt.Sprintf("user_id=eq.%s&expires_at=lt.%s", userID, time.Now().UTC().Format(time.RFC3339))
+	if err := a.Supabase.Delete("oauth_states", filter); err != nil {
+		return err
+	}
+	_, err := a.Supabase.Insert("oauth_states", map[string]any{
+		"nonce":      nonce,
+		"user_id":    userID,
+		"provider":   provider,
+		"expires_at": expiry.Format(time.RFC3339),
+	})
+	return err
+}
+
+// consumeOAuthState deletes the stored nonce, so each connect URL completes
+// at most once.
+func (a *App) consumeOAuthState(userID, provider, nonce string) error {
+	filter := fmt.Sprintf("nonce=eq.%s&user_id=eq.%s&provider=eq.%s&expires_at=gt.%s", nonce, userID, provider, time.Now().UTC().Format(time.RFC3339))
+	data, err := a.Supabase.DeleteReturning("oauth_states", filter)
+	if err != nil {
+		return err
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return errors.New("unknown state")
+	}
+	return nil
+}
+
+func (a *App) requestToken(provider string, form url.Values) (oauthToken, error) {
+	config, endpoints, ok := a.oauthConfig(provider)
+	if !ok {
+		return oauthToken{}, fmt.Errorf("%s oauth is not configured", provider)
+	}
+	form.Set("client_id", config.ClientID)
+	form.Set("client_secret", config.ClientSecret)
+	resp, err := calendars.APIClient().PostForm(endpoints.tokenURL, form)
+	if err != nil {
+		return oauthToken{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return oauthToken{}, fmt.Errorf("%s token error: %s", provider, resp.Status)
+	}
+	var token oauthToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return oauthToken{}, err
+	}
+	return token, nil
+}
+
+func (a *App) saveConnection(userID, provider string, token oauthToken) error {
+	row := map[string]any{
+		"user_id":    userID,
+		"provider":   provider,
+		"scope":      token.Scope,
+		"expires_at": time.Now().Add(time.Duration(token.ExpiresIn) * time.Second).UTC().Format(time.RFC3339),
+		"updated_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	accessToken, err := a.Secrets.Seal(token.AccessToken)
+	if err != nil {
+		return err
+	}
+	row["access_token"] = accessToken
+	if token.RefreshToken != "" {
+		refreshToken, err := a.Secrets.Seal(token.RefreshToken)
+		if err != nil {
+			return err
+		}
+		row["refresh_token"] = refreshToken
+	}
+	_, err = a.Supabase.UpsertOn("calendar_connections", "user_id,provider", row)
+	return err
+}
+
+func (a *App) accessToken(userID, provider string) (string, error) {
+	if a.Secrets == nil {
+		return "", errNotConnected
+	}
+	query := url.Values{}
+	query.Set("select", "access_token,refresh_token,expires_at")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("provider", fmt.Sprintf("eq.%s", provider))
+	data, err := a.Supabase.Select("calendar_connections", query)
+	if err != nil {
+		return "", err
+	}
+	var rows []struct {
+		AccessToken  string    `json:"access_token"`
+		RefreshToken string    `json:"refresh_token"`
+		ExpiresAt    time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return "", err
+	}
+	if len(rows) == 0 || rows[0].RefreshToken == "" {
+		return "", errNotConnected
+	}
+	row := rows[0]
+	if row.AccessToken != "" && time.Now().Add(tokenRefreshGap).Before(row.ExpiresAt) {
+		if accessToken, err := a.Secrets.Open(row.AccessToken); err == nil {
+			return accessToken, nil
+		}
+	}
+	refreshToken, err := a.Secrets.Open(row.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	token, err := a.requestToken(provider, form)
+	if err != nil {
+		return "", err
+	}
+	if err := a.saveConnection(userID, provider, token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"cal-enderBE/internal/secretbox"
+	"cal-enderBE/internal/supabase"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type fakeOAuthStore struct {
+	mu          sync.Mutex
+	states      map[string]map[string]any
+	connections map[string]map[string]any
+}
+
+func (f *fakeOAuthStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := strings.TrimPrefix(r.URL.Path, "/rest/v1/")
+	query := r.URL.Query()
+	var out any = []any{}
+	switch {
+	case r.Method == "POST" && table == "oauth_states":
+		var row map[string]any
+		json.NewDecoder(r.Body).Decode(&row)
+		f.states[row["nonce"].(string)] = row
+		out = []map[string]any{row}
+	case r.Method == "DELETE" && table == "oauth_states":
+		rows := []map[string]any{}
+		nonce := strings.TrimPrefix(query.Get("nonce"), "eq.")
+		row, ok := f.states[nonce]
+		if ok && row["user_id"] == strings.TrimPrefix(query.Get("user_id"), "eq.") &&
+			row["provider"] == strings.TrimPrefix(query.Get("provider"), "eq.") &&
+			row["expires_at"].(string) > strings.TrimPrefix(query.Get("expires_at"), "gt.") {
+			delete(f.states, nonce)
+			rows = append(rows, row)
+		}
+		out = rows
+	case r.Method == "POST" && table == "calendar_connections":
+		var row map[string]any
+		json.NewDecoder(r.Body).Decode(&row)
+		f.connections[row["user_id"].(string)+"/"+row["provider"].(string)] = row
+		out = []map[string]any{row}
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func TestOAuthConnectThenCallback(t *testing.T) {
+	store := &fakeOAuthStore{states: map[string]map[string]any{}, connections: map[string]map[string]any{}}
+	database := httptest.NewServer(store)
+	defer database.Close()
+
+	secrets, err := secretbox.New(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &App{Supabase: supabase.NewClient(database.URL, "service", "anon"), Secrets: secrets}
+	router := chi.NewRouter()
+	router.Get("/api/integrations/{provider}/callback", app.OAuthCallback)
+	router.With(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey, "user-1")))
+		})
+	}).Get("/api/integrations/{provider}/connect", app.ConnectCalendar)
+	api := httptest.NewServer(router)
+	defer api.Close()
+
+	// The provider approves consent straight away and sends the browser back
+	// to the callback with a code and the state it was given.
+	provider := http.NewServeMux()
+	provider.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		back := url.Values{}
+		back.Set("code", "code-1")
+		back.Set("state", r.URL.Query().Get("state"))
+		http.Redirect(w, r, r.URL.Query().Get("redirect_uri")+"?"+back.Encode(), http.StatusFound)
+	})
+	provider.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code-1" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(oauthToken{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 3600})
+	})
+	accounts := httptest.NewServer(provider)
+	defer accounts.Close()
+
+	app.OAuth = map[string]OAuthConfig{"google": {
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  api.URL + "/api/integrations/google/callback",
+		AuthURL:      accounts.URL + "/auth",
+		TokenURL:     accounts.URL + "/token",
+	}}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := &http.Client{Jar: jar}
+
+	resp, err := browser.Get(api.URL + "/api/integrations/google/connect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var connect struct {
+		URL string `json:"url"`
+	}
+	json.NewDecoder(resp.Body).Decode(&connect)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || connect.URL == "" {
+		t.Fatalf("connect: got %s, want a consent url", resp.Status)
+	}
+
+	var callbackURL string
+	browser.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		callbackURL = req.URL.String()
+		return nil
+	}
+	resp, err = browser.Get(connect.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("callback: got %s, want 200", resp.Status)
+	}
+	if _, ok := store.connections["user-1/google"]; !ok {
+		t.Fatalf("callback did not save a connection for user-1")
+	}
+	if len(store.states) != 0 {
+		t.Fatalf("got %d stored states after the callback, want none", len(store.states))
+	}
+
+	resp, err = browser.Get(callbackURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("replayed callback: got %s, want 400", resp.Status)
+	}
+}
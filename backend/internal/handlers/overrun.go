@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type overrunRequest struct {
+	Minutes int `json:"minutes"`
+}
+
+func (a *App) OverrunTask(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	taskID := chi.URLParam(r, "id")
+	minutes, ok := overrunMinutes(w, r)
+	if !ok {
+		return
+	}
+	settings := a.loadSettings(userID)
+	now := time.Now().In(settings.Location)
+
+	unlock := a.lockUser(userID)
+	defer unlock()
+
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("id", fmt.Sprintf("eq.%s", taskID))
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	tasks, err := a.loadTasks(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(tasks) == 0 {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	task := tasks[0]
+	if task.StartTime == nil || task.EndTime == nil || task.TaskDate != now.Format("2006-01-02") {
+		http.Error(w, "only tasks scheduled today can run long", http.StatusBadRequest)
+		return
+	}
+	if scheduler.ToMinutes(*task.StartTime) > now.Hour()*60+now.Minute() {
+		http.Error(w, "task has not started yet", http.StatusBadRequest)
+		return
+	}
+	// Tasks have no end_date, so unlike events they can't run into tomorrow.
+	end, _, ok := extendEnd(w, *task.EndTime, minutes, false)
+	if !ok {
+		return
+	}
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", taskID, userID)
+	if _, err := a.Supabase.Update("tasks", filter, map[string]any{"end_time": end}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := a.recordOverrun(userID, map[string]any{"task_id": taskID}, *task.StartTime, end, minutes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	a.finishOverrun(w, userID, settings, now, end)
+}
+
+func (a *App) OverrunEvent(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	eventID := chi.URLParam(r, "id")
+	minutes, ok := overrunMinutes(w, r)
+	if !ok {
+		return
+	}
+	settings := a.loadSettings(userID)
+	now := time.Now().In(settings.Location)
+	today := now.Format("2006-01-02")
+
+	unlock := a.lockUser(userID)
+	defer unlock()
+
+	if masterID, date, recurring := strings.Cut(eventID, ":"); recurring {
+		master, err := a.loadRecurringEvent(userID, masterID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if master == nil || !hasOccurrence(master, date) {
+			http.Error(w, "event not found", http.StatusNotFound)
+			return
+		}
+		if date != today {
+			http.Error(w, "only events happening today can run long", http.StatusBadRequest)
+			return
+		}
+		start, _ := master["start_time"].(string)
+		current, _ := master["end_time"].(string)
+		end, nextDay, ok := extendEnd(w, current, minutes, true)
+		if !ok {
+			return
+		}
+		changes := map[string]any{"end_time": end}
+		if nextDay {
+			changes["end_date"] = addDate(date, occurrenceSpan(master)+1)
+		}
+		if _, err := a.saveOverride(userID, master, date, changes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if err := a.recordOverrun(userID, map[string]any{"event_id": masterID}, start, end, minutes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		a.finishOverrun(w, userID, settings, now, end)
+		return
+	}
+
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("id", fmt.Sprintf("eq.%s", eventID))
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("recurrence", "is.null")
+	data, err := a.Supabase.Select("calendar_events", query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	var events []scheduler.Event
+	json.Unmarshal(data, &events)
+	if len(events) == 0 {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+	event := events[0]
+	if event.AllDay || event.EventDate != today || (event.EndDate != nil && *event.EndDate != "" && *event.EndDate != today) {
+		http.Error(w, "only events happening today can run long", http.StatusBadRequest)
+		return
+	}
+	end, nextDay, ok := extendEnd(w, event.EndTime, minutes, true)
+	if !ok {
+		return
+	}
+	changes := map[string]any{"end_time": end}
+	if nextDay {
+		changes["end_date"] = addDate(today, 1)
+	}
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", eventID, userID)
+	if _, err := a.Supabase.Update("calendar_events", filter, changes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := a.recordOverrun(userID, map[string]any{"event_id": eventID}, event.StartTime, end, minutes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	a.finishOverrun(w, userID, settings, now, end)
+}
+
+func (a *App) recordOverrun(userID string, row map[string]any, start, end string, minutes int) error {
+	row["user_id"] = userID
+	row["start_time"] = start
+	row["end_time"] = end
+	row["overrun_minutes"] = minutes
+	_, err := a.Supabase.Insert("behavioral_data", row)
+	return err
+}
+
+func (a *App) finishOverrun(w http.ResponseWriter, userID string, settings scheduler.Settings, now time.Time, end string) {
+	result, err := a.reflow(userID, settings, now, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"end_time":              end,
+		"updated":               len(result.Updates),
+		"inserted":              len(result.Inserts),
+		"unplaced":              result.Unplaced,
+		"deadline_violations":   result.DeadlineViolations,
+		"finishes":              result.Finishes,
+		"constraint_violations": result.ConstraintViolations,
+	})
+}
+
+func overrunMinutes(w http.ResponseWriter, r *http.Request) (int, bool) {
+	var request overrunRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Minutes <= 0 {
+		http.Error(w, "minutes must be a positive number", http.StatusBadRequest)
+		return 0, false
+	}
+	return request.Minutes, true
+}
+
+// extendEnd adds minutes to an end time. When overnight is allowed an end
+// past midnight wraps into the next day, which nextDay reports.
+func extendEnd(w http.ResponseWriter, current string, minutes int, overnight bool) (string, bool, bool) {
+	end := scheduler.ToMinutes(current) + minutes
+	nextDay := end >= 24*60
+	switch {
+	case nextDay && !overnight:
+		http.Error(w, "tasks can't run past midnight", http.StatusBadRequest)
+		return "", false, false
+	case end >= 48*60:
+		http.Error(w, "overrun would run past tomorrow", http.StatusBadRequest)
+		return "", false, false
+	case nextDay:
+		end -= 24 * 60
+	}
+	return fmt.Sprintf("%02d:%02d", end/60, end%60), nextDay, true
+}
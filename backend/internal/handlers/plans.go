@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cal-enderBE/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const planTTL = 15 * time.Minute
+
+type schedulePlan struct {
+	ID          string                   `json:"id"`
+	UserID      string                   `json:"user_id"`
+	Request     scheduleRequest          `json:"request"`
+	Result      scheduler.ScheduleResult `json:"result"`
+	Fingerprint string                   `json:"fingerprint"`
+	ExpiresAt   time.Time                `json:"expires_at"`
+}
+
+func (a *App) storePlan(userID string, request scheduleRequest, result scheduler.ScheduleResult, fingerprint string) (schedulePlan, error) {
+	now := time.Now().UTC()
+	filter := fmt.Sprintf("user_id=eq.%s&expires_at=lt.%s", userID, now.Format(time.RFC3339))
+	if err := a.Supabase.Delete("schedule_plans", filter); err != nil {
+		return schedulePlan{}, err
+	}
+	plan := schedulePlan{
+		ID:          newPlanID(),
+		UserID:      userID,
+		Request:     request,
+		Result:      result,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(planTTL),
+	}
+	_, err := a.Supabase.Insert("schedule_plans", plan)
+	return plan, err
+}
+
+func (a *App) loadPlan(userID, planID string) (*schedulePlan, error) {
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("id", fmt.Sprintf("eq.%s", planID))
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	data, err := a.Supabase.Select("schedule_plans", query)
+	if err != nil {
+		return nil, err
+	}
+	var plans []schedulePlan
+	if err := json.Unmarshal(data, &plans); err != nil {
+		return nil, fmt.Errorf("invalid schedule plan payload")
+	}
+	if len(plans) == 0 {
+		return nil, nil
+	}
+	return &plans[0], nil
+}
+
+func (a *App) deletePlan(userID, planID string) {
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", planID, userID)
+	if err := a.Supabase.Delete("schedule_plans", filter); err != nil {
+		log.Printf("plans: user %s: %v", userID, err)
+	}
+}
+
+func (a *App) ApplySchedulePlan(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	planID := chi.URLParam(r, "id")
+
+	unlock := a.lockUser(userID)
+	defer unlock()
+
+	plan, err := a.loadPlan(userID, planID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if plan == nil {
+		http.Error(w, "plan not found", http.StatusNotFound)
+		return
+	}
+	if plan.ExpiresAt.Before(time.Now()) {
+		a.deletePlan(userID, planID)
+		http.Error(w, "plan expired", http.StatusGone)
+		return
+	}
+	settings := a.loadSettings(userID)
+	_, fingerprint, err := a.planSchedule(userID, plan.Request, settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if fingerprint != plan.Fingerprint {
+		a.deletePlan(userID, planID)
+		http.Error(w, "schedule changed since preview", http.StatusConflict)
+		return
+	}
+	if _, err := a.materializeTemplates(userID, settings.Location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := a.resolvePendingTasks(userID, &plan.Result); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := a.saveScheduleResult(userID, plan.Result, settings.Location); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	a.deletePlan(userID, planID)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"updated":               len(plan.Result.Updates),
+		"inserted":              len(plan.Result.Inserts),
+		"unplaced":              plan.Result.Unplaced,
+		"deadline_violations":   plan.Result.DeadlineViolations,
+		"finishes":              plan.Result.Finishes,
+		"constraint_violations": plan.Result.ConstraintViolations,
+	})
+}
+
+func scheduleFingerprint(tasks []scheduler.Task, events []scheduler.Event, settings scheduler.Settings) string {
+	// *time.Location marshals to {}, so the timezone is added by name.
+	location := ""
+	if settings.Location != nil {
+		location = settings.Location.String()
+	}
+	encoded, _ := json.Marshal(map[string]any{
+		"tasks":    tasks,
+		"events":   events,
+		"settings": settings,
+		"location": location,
+	})
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+func newPlanID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
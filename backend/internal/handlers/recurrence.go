@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/ical"
+	"cal-enderBE/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var overrideFields = []string{"title", "event_date", "end_date", "start_time", "end_time", "all_day"}
+
+func (a *App) OverrideOccurrence(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	master, date, ok := a.loadOccurrence(w, r, userID)
+	if !ok {
+		return
+	}
+	response, err := a.saveOverride(userID, master, date, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Write(response)
+}
+
+func (a *App) saveOverride(userID string, master map[string]any, date string, changes map[string]any) ([]byte, error) {
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("recurring_event_id", fmt.Sprintf("eq.%s", master["id"]))
+	query.Set("recurrence_date", fmt.Sprintf("eq.%s", date))
+	data, err := a.Supabase.Select("calendar_events", query)
+	if err != nil {
+		return nil, err
+	}
+	var existing []map[string]any
+	json.Unmarshal(data, &existing)
+	row := map[string]any{
+		"user_id":            userID,
+		"source":             master["source"],
+		"calendar_id":        master["calendar_id"],
+		"is_fixed":           master["is_fixed"],
+		"recurring_event_id": master["id"],
+		"recurrence_date":    date,
+		"event_date":         date,
+		"end_date":           addDate(date, occurrenceSpan(master)),
+	}
+	for _, key := range overrideFields {
+		if _, ok := row[key]; !ok {
+			row[key] = master[key]
+		}
+		if len(existing) > 0 {
+			row[key] = existing[0][key]
+		}
+		if value, ok := changes[key]; ok {
+			row[key] = value
+		}
+	}
+	return a.Supabase.UpsertOn("calendar_events", "recurring_event_id,recurrence_date", row)
+}
+
+func (a *App) CancelOccurrence(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	master, date, ok := a.loadOccurrence(w, r, userID)
+	if !ok {
+		return
+	}
+	exdates := []string{date}
+	if values, ok := master["exdates"].([]any); ok {
+		for _, value := range values {
+			if existing, ok := value.(string); ok && existing != date {
+				exdates = append(exdates, existing)
+			}
+		}
+	}
+	sort.Strings(exdates)
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", master["id"], userID)
+	if _, err := a.Supabase.Update("calendar_events", filter, map[string]any{"exdates": exdates}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	filter = fmt.Sprintf("user_id=eq.%s&recurring_event_id=eq.%s&recurrence_date=eq.%s", userID, master["id"], date)
+	if err := a.Supabase.Delete("calendar_events", filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
+}
+
+func (a *App) loadOccurrence(w http.ResponseWriter, r *http.Request, userID string) (map[string]any, string, bool) {
+	date := chi.URLParam(r, "date")
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		http.Error(w, "invalid occurrence date", http.StatusBadRequest)
+		return nil, "", false
+	}
+	master, err := a.loadRecurringEvent(userID, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return nil, "", false
+	}
+	if master == nil {
+		http.Error(w, "recurring event not found", http.StatusNotFound)
+		return nil, "", false
+	}
+	if !hasOccurrence(master, date) {
+		http.Error(w, "no occurrence on that date", http.StatusNotFound)
+		return nil, "", false
+	}
+	return master, date, true
+}
+
+func (a *App) loadRecurringEvent(userID, eventID string) (map[string]any, error) {
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("id", fmt.Sprintf("eq.%s", eventID))
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("recurrence", "not.is.null")
+	data, err := a.Supabase.Select("calendar_events", query)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]any
+	json.Unmarshal(data, &rows)
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return rows[0], nil
+}
+
+func hasOccurrence(master map[string]any, date string) bool {
+	for _, occurrence := range expandRecurring([]map[string]any{master}, date, date) {
+		if occurrence["recurrence_date"] == date {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) recurringEvents(userID, from, to string) ([]map[string]any, error) {
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("or", "(recurrence.not.is.null,recurring_event_id.not.is.null)")
+	data, err := a.Supabase.Select("calendar_events", query)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("invalid events payload")
+	}
+	return expandRecurring(rows, from, to), nil
+}
+
+func normalizeRecurrence(payload map[string]any) error {
+	value, ok := payload["recurrence"].(string)
+	if !ok {
+		return nil
+	}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		payload["recurrence"] = nil
+		return nil
+	}
+	if _, err := ical.ParseRule(value, time.UTC); err != nil {
+		return err
+	}
+	payload["recurrence"] = value
+	return nil
+}
+
+func expandRecurring(rows []map[string]any, from, to string) []map[string]any {
+	overridden := map[string]bool{}
+	out := []map[string]any{}
+	for _, row := range rows {
+		masterID, _ := row["recurring_event_id"].(string)
+		if masterID == "" {
+			continue
+		}
+		if date, ok := row["recurrence_date"].(string); ok {
+			overridden[masterID+"|"+date] = true
+		}
+		if rowOverlaps(row, from, to) {
+			out = append(out, row)
+		}
+	}
+	fromDay, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return out
+	}
+	toDay, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return out
+	}
+	for _, row := range rows {
+		value, _ := row["recurrence"].(string)
+		start, err := time.Parse("2006-01-02", stringValue(row["event_date"]))
+		if value == "" || err != nil {
+			continue
+		}
+		rule, err := ical.ParseRule(value, time.UTC)
+		if err != nil {
+			continue
+		}
+		id := stringValue(row["id"])
+		span := occurrenceSpan(row)
+		excluded := map[string]bool{}
+		if values, ok := row["exdates"].([]any); ok {
+			for _, value := range values {
+				excluded[stringValue(value)] = true
+			}
+		}
+		for _, day := range rule.Between(start, fromDay.AddDate(0, 0, -span), toDay.AddDate(0, 0, 1)) {
+			date := day.Format("2006-01-02")
+			if excluded[date] || overridden[id+"|"+date] {
+				continue
+			}
+			occurrence := make(map[string]any, len(row)+2)
+			for key, value := range row {
+				occurrence[key] = value
+			}
+			occurrence["id"] = id + ":" + date
+			occurrence["recurring_event_id"] = id
+			occurrence["recurrence_date"] = date
+			occurrence["event_date"] = date
+			occurrence["end_date"] = addDate(date, span)
+			out = append(out, occurrence)
+		}
+	}
+	sortEventRows(out)
+	return out
+}
+
+func sortEventRows(rows []map[string]any) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if left, right := stringValue(rows[i]["event_date"]), stringValue(rows[j]["event_date"]); left != right {
+			return left < right
+		}
+		return stringValue(rows[i]["start_time"]) < stringValue(rows[j]["start_time"])
+	})
+}
+
+func occurrenceSpan(row map[string]any) int {
+	start, err := time.Parse("2006-01-02", stringValue(row["event_date"]))
+	if err != nil {
+		return 0
+	}
+	end, err := time.Parse("2006-01-02", stringValue(row["end_date"]))
+	if err != nil || end.Before(start) {
+		return 0
+	}
+	return int(end.Sub(start).Hours() / 24)
+}
+
+func rowOverlaps(row map[string]any, from, to string) bool {
+	start := stringValue(row["event_date"])
+	end := stringValue(row["end_date"])
+	if end == "" {
+		end = start
+	}
+	return start <= to && end >= from
+}
+
+func addDate(date string, days int) string {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return day.AddDate(0, 0, days).Format("2006-01-02")
+}
+
+func stringValue(value any) string {
+	text, _ := value.(string)
+	return text
+}
+
+func eventsFromRows(rows []map[string]any) ([]scheduler.Event, error) {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	var events []scheduler.Event
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("invalid events payload")
+	}
+	return events, nil
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"cal-enderBE/internal/scheduler"
+)
+
+const activeUserPageSize = 1000
+
+// ActiveUserIDs pages through the reflow_users view, which lists each user
+// with an unfinished task once.
+func (a *App) ActiveUserIDs() ([]string, error) {
+	userIDs := []string{}
+	for offset := 0; ; {
+		query := url.Values{}
+		query.Set("select", "user_id")
+		query.Set("order", "user_id.asc")
+		query.Set("limit", fmt.Sprintf("%d", activeUserPageSize))
+		query.Set("offset", fmt.Sprintf("%d", offset))
+		data, err := a.Supabase.Select("reflow_users", query)
+		if err != nil {
+			return nil, err
+		}
+		var rows []struct {
+			UserID string `json:"user_id"`
+		}
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if row.UserID != "" {
+				userIDs = append(userIDs, row.UserID)
+			}
+		}
+		if len(rows) == 0 {
+			return userIDs, nil
+		}
+		offset += len(rows)
+	}
+}
+
+func (a *App) Reflow(userID string, now time.Time) (scheduler.ScheduleResult, error) {
+	unlock := a.lockUser(userID)
+	defer unlock()
+	return a.reflow(userID, a.loadSettings(userID), now, false)
+}
+
+func (a *App) reflow(userID string, settings scheduler.Settings, now time.Time, releaseToday bool) (scheduler.ScheduleResult, error) {
+	if _, err := a.materializeTemplates(userID, settings.Location); err != nil {
+		return scheduler.ScheduleResult{}, err
+	}
+	now = now.In(settings.Location)
+	today := now.Format("2006-01-02")
+	nowMinutes := now.Hour()*60 + now.Minute()
+
+	upcomingQuery := url.Values{}
+	upcomingQuery.Set("select", "*")
+	upcomingQuery.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	upcomingQuery.Set("task_date", fmt.Sprintf("gte.%s", today))
+	tasks, err := a.loadTasks(upcomingQuery)
+	if err != nil {
+		return scheduler.ScheduleResult{}, err
+	}
+	overdueQuery := url.Values{}
+	overdueQuery.Set("select", "*")
+	overdueQuery.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	overdueQuery.Set("task_date", fmt.Sprintf("lt.%s", today))
+	overdueQuery.Set("or", "(status.is.null,status.neq.completed)")
+	overdue, err := a.loadTasks(overdueQuery)
+	if err != nil {
+		return scheduler.ScheduleResult{}, err
+	}
+	tasks = scheduler.ReleasePastDue(append(overdue, tasks...), today, nowMinutes)
+	if releaseToday {
+		tasks = scheduler.ReleaseRestOfDay(tasks, today, nowMinutes)
+	}
+
+	events, err := a.loadEvents(userID, today)
+	if err != nil {
+		return scheduler.ScheduleResult{}, err
+	}
+	settings.DaysOff = a.loadDaysOff(userID, today)
+	settings.StartDate = today
+	settings.StartMinutes = nowMinutes
+	a.applyEstimates(userID, tasks)
+	a.applyFocusProfile(userID, &settings)
+
+	result := scheduler.AutoSchedule(tasks, events, settings, "", false)
+	if err := a.saveScheduleResult(userID, result, settings.Location); err != nil {
+		return scheduler.ScheduleResult{}, err
+	}
+	return result, nil
+}
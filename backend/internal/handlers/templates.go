@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/ical"
+	"cal-enderBE/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	templateLookaheadDays = 14
+	pendingTaskPrefix     = "template:"
+)
+
+type taskTemplate struct {
+	ID                string  `json:"id"`
+	Title             string  `json:"title"`
+	Company           string  `json:"company"`
+	Project           string  `json:"project"`
+	ProjectID         *string `json:"project_id"`
+	EstimatedHours    float64 `json:"estimated_hours"`
+	PriorityLevel     int     `json:"priority_level"`
+	DeadlineType      *string `json:"deadline_type"`
+	Recurrence        string  `json:"recurrence"`
+	StartDate         string  `json:"start_date"`
+	WindowStartOffset int     `json:"window_start_offset"`
+	DueOffset         int     `json:"due_offset"`
+	GeneratedThrough  *string `json:"generated_through"`
+	Notes             *string `json:"notes"`
+}
+
+func (a *App) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userIDFromContext(r)))
+	query.Set("order", "created_at.desc")
+	response, err := a.Supabase.Select("task_templates", query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Write(response)
+}
+
+func (a *App) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if err := normalizeRecurrence(payload); err != nil || payload["recurrence"] == nil {
+		http.Error(w, "a valid recurrence rule is required", http.StatusBadRequest)
+		return
+	}
+	loc := a.userLocation(userID)
+	if _, ok := payload["start_date"]; !ok {
+		payload["start_date"] = time.Now().In(loc).Format("2006-01-02")
+	}
+	if !validTemplateStart(payload) {
+		http.Error(w, "start_date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	payload["user_id"] = userID
+	delete(payload, "generated_through")
+	response, err := a.Supabase.Insert("task_templates", payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	a.materializeAfterChange(userID, loc)
+	w.Write(response)
+}
+
+func (a *App) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if _, ok := payload["recurrence"]; ok {
+		if err := normalizeRecurrence(payload); err != nil || payload["recurrence"] == nil {
+			http.Error(w, "a valid recurrence rule is required", http.StatusBadRequest)
+			return
+		}
+	}
+	if _, ok := payload["start_date"]; ok && !validTemplateStart(payload) {
+		http.Error(w, "start_date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	payload["user_id"] = userID
+	delete(payload, "generated_through")
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", chi.URLParam(r, "id"), userID)
+	response, err := a.Supabase.Update("task_templates", filter, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	a.materializeAfterChange(userID, a.userLocation(userID))
+	w.Write(response)
+}
+
+func (a *App) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", chi.URLParam(r, "id"), userID)
+	if err := a.Supabase.Delete("task_templates", filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (a *App) materializeAfterChange(userID string, loc *time.Location) {
+	unlock := a.lockUser(userID)
+	defer unlock()
+	if _, err := a.materializeTemplates(userID, loc); err != nil {
+		log.Printf("templates: user %s: %v", userID, err)
+	}
+}
+
+func (a *App) materializeTemplates(userID string, loc *time.Location) (int, error) {
+	templates, err := a.activeTemplates(userID)
+	if err != nil {
+		return 0, err
+	}
+	today := time.Now().In(loc).Format("2006-01-02")
+	through := addDate(today, templateLookaheadDays)
+	created := 0
+	for _, template := range templates {
+		count, err := a.materializeTemplate(userID, template, today, through)
+		if err != nil {
+			log.Printf("templates: user %s: template %s: %v", userID, template.ID, err)
+			continue
+		}
+		created += count
+	}
+	return created, nil
+}
+
+func (a *App) activeTemplates(userID string) ([]taskTemplate, error) {
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("active", "is.true")
+	data, err := a.Supabase.Select("task_templates", query)
+	if err != nil {
+		return nil, err
+	}
+	var templates []taskTemplate
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("invalid templates payload")
+	}
+	return templates, nil
+}
+
+func (a *App) materializeTemplate(userID string, template taskTemplate, today, through string) (int, error) {
+	rows, err := a.missingTemplateRows(userID, template, today, through)
+	if err != nil {
+		return 0, err
+	}
+	created := 0
+	if len(rows) > 0 {
+		inserted, err := a.Supabase.InsertIgnoreOn("tasks", "template_id,period_date", rows)
+		if err != nil {
+			return 0, err
+		}
+		var ids []map[string]any
+		json.Unmarshal(inserted, &ids)
+		created = len(ids)
+	}
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", template.ID, userID)
+	if _, err := a.Supabase.Update("task_templates", filter, map[string]any{"generated_through": through}); err != nil {
+		return created, err
+	}
+	return created, nil
+}
+
+// missingTemplateRows builds the task rows for the template's periods up to
+// through that have no task yet, without writing anything.
+func (a *App) missingTemplateRows(userID string, template taskTemplate, today, through string) ([]map[string]any, error) {
+	periods, err := templatePeriods(template, today, through)
+	if err != nil || len(periods) == 0 {
+		return nil, err
+	}
+	existing, err := a.templateInstances(userID, template.ID, periods)
+	if err != nil {
+		return nil, err
+	}
+	rows := []map[string]any{}
+	for _, period := range periods {
+		if !existing[period] {
+			rows = append(rows, templateInstance(userID, template, period, today))
+		}
+	}
+	return rows, nil
+}
+
+// pendingTemplateTasks returns the instances materializeTemplates would
+// create, with placeholder ids, so a preview can schedule them in memory.
+func (a *App) pendingTemplateTasks(userID string, loc *time.Location) ([]scheduler.Task, error) {
+	templates, err := a.activeTemplates(userID)
+	if err != nil {
+		return nil, err
+	}
+	today := time.Now().In(loc).Format("2006-01-02")
+	through := addDate(today, templateLookaheadDays)
+	tasks := []scheduler.Task{}
+	for _, template := range templates {
+		rows, err := a.missingTemplateRows(userID, template, today, through)
+		if err != nil {
+			log.Printf("templates: user %s: template %s: %v", userID, template.ID, err)
+			continue
+		}
+		for _, row := range rows {
+			encoded, _ := json.Marshal(row)
+			var task scheduler.Task
+			if err := json.Unmarshal(encoded, &task); err != nil {
+				return nil, err
+			}
+			task.ID = pendingTaskID(template.ID, row["period_date"].(string))
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+func pendingTaskID(templateID, period string) string {
+	return pendingTaskPrefix + templateID + ":" + period
+}
+
+// resolvePendingTasks swaps the placeholder ids in a plan for the ids of the
+// rows materializeTemplates has since inserted.
+func (a *App) resolvePendingTasks(userID string, result *scheduler.ScheduleResult) error {
+	templateIDs := map[string]bool{}
+	for _, update := range result.Updates {
+		if rest, ok := strings.CutPrefix(update.ID, pendingTaskPrefix); ok {
+			templateID, _, _ := strings.Cut(rest, ":")
+			templateIDs[templateID] = true
+		}
+	}
+	for _, insert := range result.Inserts {
+		if insert.ParentTaskID == nil {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(*insert.ParentTaskID, pendingTaskPrefix); ok {
+			templateID, _, _ := strings.Cut(rest, ":")
+			templateIDs[templateID] = true
+		}
+	}
+	if len(templateIDs) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(templateIDs))
+	for templateID := range templateIDs {
+		ids = append(ids, templateID)
+	}
+	query := url.Values{}
+	query.Set("select", "id,template_id,period_date")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("template_id", fmt.Sprintf("in.(%s)", strings.Join(ids, ",")))
+	data, err := a.Supabase.Select("tasks", query)
+	if err != nil {
+		return err
+	}
+	var rows []struct {
+		ID         string `json:"id"`
+		TemplateID string `json:"template_id"`
+		PeriodDate string `json:"period_date"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return fmt.Errorf("invalid tasks payload")
+	}
+	resolved := map[string]string{}
+	for _, row := range rows {
+		resolved[pendingTaskID(row.TemplateID, row.PeriodDate)] = row.ID
+	}
+	for i, update := range result.Updates {
+		if !strings.HasPrefix(update.ID, pendingTaskPrefix) {
+			continue
+		}
+		id, ok := resolved[update.ID]
+		if !ok {
+			return fmt.Errorf("template instance %s was not created", update.ID)
+		}
+		result.Updates[i].ID = id
+	}
+	for i, insert := range result.Inserts {
+		if insert.ParentTaskID == nil || !strings.HasPrefix(*insert.ParentTaskID, pendingTaskPrefix) {
+			continue
+		}
+		id, ok := resolved[*insert.ParentTaskID]
+		if !ok {
+			return fmt.Errorf("template instance %s was not created", *insert.ParentTaskID)
+		}
+		result.Inserts[i].ParentTaskID = &id
+	}
+	return nil
+}
+
+func validTemplateStart(payload map[string]any) bool {
+	value, ok := payload["start_date"].(string)
+	if !ok {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+func templatePeriods(template taskTemplate, today, through string) ([]string, error) {
+	start, err := time.Parse("2006-01-02", template.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: invalid start_date", template.ID)
+	}
+	rule, err := ical.ParseRule(template.Recurrence, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	lookback := template.DueOffset
+	if lookback < 0 {
+		lookback = 0
+	}
+	fromDate := addDate(today, -lookback)
+	if template.GeneratedThrough != nil && *template.GeneratedThrough >= fromDate {
+		fromDate = addDate(*template.GeneratedThrough, 1)
+	}
+	from, _ := time.Parse("2006-01-02", fromDate)
+	to, _ := time.Parse("2006-01-02", through)
+	periods := []string{}
+	for _, day := range rule.Between(start, from, to.AddDate(0, 0, 1)) {
+		periods = append(periods, day.Format("2006-01-02"))
+	}
+	return periods, nil
+}
+
+func templateInstance(userID string, template taskTemplate, period, today string) map[string]any {
+	earliest := addDate(period, template.WindowStartOffset)
+	taskDate := earliest
+	if taskDate < today {
+		taskDate = today
+	}
+	deadlineType := "hard"
+	if template.DeadlineType != nil && *template.DeadlineType != "" {
+		deadlineType = *template.DeadlineType
+	}
+	row := map[string]any{
+		"user_id":         userID,
+		"template_id":     template.ID,
+		"period_date":     period,
+		"title":           template.Title,
+		"company":         template.Company,
+		"project":         template.Project,
+		"project_id":      template.ProjectID,
+		"notes":           template.Notes,
+		"task_date":       taskDate,
+		"earliest_date":   earliest,
+		"latest_date":     addDate(period, template.DueOffset),
+		"estimated_hours": template.EstimatedHours,
+		"priority_level":  template.PriorityLevel,
+		"deadline_type":   deadlineType,
+		"deadline_date":   addDate(period, template.DueOffset),
+		"dependencies":    []string{},
+		"status":          "planned",
+	}
+	if template.PriorityLevel == 0 {
+		row["priority_level"] = 2
+	}
+	return row
+}
+
+func (a *App) templateInstances(userID, templateID string, periods []string) (map[string]bool, error) {
+	query := url.Values{}
+	query.Set("select", "period_date")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("template_id", fmt.Sprintf("eq.%s", templateID))
+	query.Set("period_date", fmt.Sprintf("in.(%s)", strings.Join(periods, ",")))
+	data, err := a.Supabase.Select("tasks", query)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		PeriodDate string `json:"period_date"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for _, row := range rows {
+		existing[row.PeriodDate] = true
+	}
+	return existing, nil
+}
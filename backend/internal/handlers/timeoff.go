@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/holidays"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *App) GetTimeOff(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	if start := r.URL.Query().Get("start"); start != "" {
+		query.Set("end_date", fmt.Sprintf("gte.%s", start))
+	}
+	if end := r.URL.Query().Get("end"); end != "" {
+		query.Set("start_date", fmt.Sprintf("lte.%s", end))
+	}
+	query.Set("order", "start_date.asc")
+	response, err := a.Supabase.Select("time_off", query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Write(response)
+}
+
+func (a *App) CreateTimeOff(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	startDate, _ := payload["start_date"].(string)
+	endDate, _ := payload["end_date"].(string)
+	if endDate == "" {
+		endDate = startDate
+		payload["end_date"] = endDate
+	}
+	if err := checkTimeOffDates(startDate, endDate); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	payload["user_id"] = userID
+	response, err := a.Supabase.Insert("time_off", payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Write(response)
+}
+
+func (a *App) UpdateTimeOff(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	timeOffID := chi.URLParam(r, "id")
+	var payload map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	_, hasStart := payload["start_date"]
+	_, hasEnd := payload["end_date"]
+	if hasStart || hasEnd {
+		query := url.Values{}
+		query.Set("select", "start_date,end_date")
+		query.Set("id", fmt.Sprintf("eq.%s", timeOffID))
+		query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+		data, err := a.Supabase.Select("time_off", query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		var rows []struct {
+			StartDate string `json:"start_date"`
+			EndDate   string `json:"end_date"`
+		}
+		if err := json.Unmarshal(data, &rows); err != nil || len(rows) == 0 {
+			http.Error(w, "time off not found", http.StatusNotFound)
+			return
+		}
+		startDate, endDate := rows[0].StartDate, rows[0].EndDate
+		if hasStart {
+			startDate, _ = payload["start_date"].(string)
+		}
+		if hasEnd {
+			endDate, _ = payload["end_date"].(string)
+		}
+		if err := checkTimeOffDates(startDate, endDate); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	payload["user_id"] = userID
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", timeOffID, userID)
+	response, err := a.Supabase.Update("time_off", filter, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Write(response)
+}
+
+func checkTimeOffDates(startDate, endDate string) error {
+	if _, err := time.Parse("2006-01-02", startDate); err != nil {
+		return fmt.Errorf("invalid start_date")
+	}
+	if _, err := time.Parse("2006-01-02", endDate); err != nil || endDate < startDate {
+		return fmt.Errorf("invalid end_date")
+	}
+	return nil
+}
+
+func (a *App) DeleteTimeOff(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	timeOffID := chi.URLParam(r, "id")
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", timeOffID, userID)
+	if err := a.Supabase.Delete("time_off", filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (a *App) ImportHolidays(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	var payload struct {
+		Country string `json:"country"`
+		Year    int    `json:"year"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if payload.Year == 0 {
+		payload.Year = time.Now().In(a.userLocation(userID)).Year()
+	}
+	list, err := holidays.ForYear(payload.Country, payload.Year)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	country := strings.ToUpper(payload.Country)
+	filter := fmt.Sprintf("user_id=eq.%s&kind=eq.holiday&country=eq.%s&start_date=gte.%04d-01-01&start_date=lte.%04d-12-31",
+		userID, country, payload.Year, payload.Year)
+	if err := a.Supabase.Delete("time_off", filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	rows := []map[string]any{}
+	for _, holiday := range list {
+		rows = append(rows, map[string]any{
+			"user_id":    userID,
+			"title":      holiday.Name,
+			"kind":       "holiday",
+			"country":    country,
+			"start_date": holiday.Date,
+			"end_date":   holiday.Date,
+		})
+	}
+	if _, err := a.Supabase.Insert("time_off", rows); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"imported": len(rows)})
+}
+
+func (a *App) loadDaysOff(userID, startDay string) map[string]bool {
+	query := url.Values{}
+	query.Set("select", "start_date,end_date")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("end_date", fmt.Sprintf("gte.%s", startDay))
+	data, err := a.Supabase.Select("time_off", query)
+	if err != nil {
+		return nil
+	}
+	var rows []struct {
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil
+	}
+	daysOff := map[string]bool{}
+	for _, row := range rows {
+		start, err := time.Parse("2006-01-02", row.StartDate)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse("2006-01-02", row.EndDate)
+		if err != nil {
+			end = start
+		}
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			daysOff[day.Format("2006-01-02")] = true
+		}
+	}
+	return daysOff
+}
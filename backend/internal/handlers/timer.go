@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cal-enderBE/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type workSession struct {
+	ID        string     `json:"id"`
+	TaskID    string     `json:"task_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+}
+
+type timerSummary struct {
+	Running          bool          `json:"running"`
+	Sessions         []workSession `json:"sessions"`
+	ActualMinutes    int           `json:"actual_minutes"`
+	EstimatedMinutes int           `json:"estimated_minutes"`
+	OverrunMinutes   int           `json:"overrun_minutes"`
+}
+
+func (a *App) GetTimer(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	task, ok := a.timerTask(w, userID, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	sessions, err := a.loadSessions(userID, task.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, summarizeSessions(task, sessions, time.Now()))
+}
+
+func (a *App) StartTimer(w http.ResponseWriter, r *http.Request) {
+	a.openSession(w, r, false)
+}
+
+func (a *App) ResumeTimer(w http.ResponseWriter, r *http.Request) {
+	a.openSession(w, r, true)
+}
+
+func (a *App) PauseTimer(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	unlock := a.lockUser(userID)
+	defer unlock()
+	task, ok := a.timerTask(w, userID, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	now := time.Now()
+	sessions, err := a.loadSessions(userID, task.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !isRunning(sessions) {
+		http.Error(w, "timer is not running", http.StatusConflict)
+		return
+	}
+	if err := a.closeSessions(userID, task.ID, now); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	sessions, err = a.loadSessions(userID, task.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, summarizeSessions(task, sessions, now))
+}
+
+func (a *App) StopTimer(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	unlock := a.lockUser(userID)
+	defer unlock()
+	task, ok := a.timerTask(w, userID, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	if strings.EqualFold(task.Status, "completed") {
+		http.Error(w, "task is already completed", http.StatusConflict)
+		return
+	}
+	sessions, err := a.loadSessions(userID, task.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(sessions) == 0 {
+		http.Error(w, "timer was never started", http.StatusConflict)
+		return
+	}
+	loc := a.userLocation(userID)
+	now := time.Now().In(loc)
+	filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s", task.ID, userID)
+	_, err = a.Supabase.Update("tasks", filter, map[string]any{
+		"status":       "completed",
+		"actual_end":   now.Format("15:04"),
+		"completed_at": now.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	summary, err := a.finishTimer(userID, task, now, loc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	a.removeTaskBlocksFor(userID, []string{task.ID})
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (a *App) openSession(w http.ResponseWriter, r *http.Request, resume bool) {
+	userID := userIDFromContext(r)
+	unlock := a.lockUser(userID)
+	defer unlock()
+	task, ok := a.timerTask(w, userID, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	if strings.EqualFold(task.Status, "completed") {
+		http.Error(w, "task is already completed", http.StatusConflict)
+		return
+	}
+	sessions, err := a.loadSessions(userID, task.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	switch {
+	case isRunning(sessions):
+		http.Error(w, "timer is already running", http.StatusConflict)
+		return
+	case resume && len(sessions) == 0:
+		http.Error(w, "timer was never started", http.StatusConflict)
+		return
+	}
+	now := time.Now()
+	if err := a.closeSessions(userID, "", now); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	_, err = a.Supabase.Insert("task_sessions", map[string]any{
+		"user_id":    userID,
+		"task_id":    task.ID,
+		"started_at": now.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if len(sessions) == 0 {
+		filter := fmt.Sprintf("id=eq.%s&user_id=eq.%s&actual_start=is.null", task.ID, userID)
+		actualStart := now.In(a.userLocation(userID)).Format("15:04")
+		if _, err := a.Supabase.Update("tasks", filter, map[string]any{"actual_start": actualStart}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	sessions, err = a.loadSessions(userID, task.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, summarizeSessions(task, sessions, now))
+}
+
+func (a *App) finishTimer(userID string, task scheduler.Task, now time.Time, loc *time.Location) (timerSummary, error) {
+	if err := a.closeSessions(userID, task.ID, now); err != nil {
+		return timerSummary{}, err
+	}
+	sessions, err := a.loadSessions(userID, task.ID)
+	if err != nil {
+		return timerSummary{}, err
+	}
+	summary := summarizeSessions(task, sessions, now)
+	if len(sessions) == 0 || summary.EstimatedMinutes <= 0 {
+		return summary, nil
+	}
+	_, err = a.Supabase.Insert("behavioral_data", map[string]any{
+		"user_id":         userID,
+		"task_id":         task.ID,
+		"start_time":      sessions[0].StartedAt.In(loc).Format("15:04"),
+		"end_time":        now.In(loc).Format("15:04"),
+		"actual_minutes":  summary.ActualMinutes,
+		"overrun_minutes": summary.OverrunMinutes,
+	})
+	return summary, err
+}
+
+func (a *App) timerTask(w http.ResponseWriter, userID, taskID string) (scheduler.Task, bool) {
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("id", fmt.Sprintf("eq.%s", taskID))
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	tasks, err := a.loadTasks(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return scheduler.Task{}, false
+	}
+	if len(tasks) == 0 {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return scheduler.Task{}, false
+	}
+	return tasks[0], true
+}
+
+func (a *App) loadSessions(userID, taskID string) ([]workSession, error) {
+	query := url.Values{}
+	query.Set("select", "id,task_id,started_at,ended_at")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("task_id", fmt.Sprintf("eq.%s", taskID))
+	query.Set("order", "started_at.asc")
+	data, err := a.Supabase.Select("task_sessions", query)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []workSession{}
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("invalid sessions payload")
+	}
+	return sessions, nil
+}
+
+func (a *App) closeSessions(userID, taskID string, now time.Time) error {
+	filter := fmt.Sprintf("user_id=eq.%s&ended_at=is.null", userID)
+	if taskID != "" {
+		filter += fmt.Sprintf("&task_id=eq.%s", taskID)
+	}
+	_, err := a.Supabase.Update("task_sessions", filter, map[string]any{"ended_at": now.UTC().Format(time.RFC3339)})
+	return err
+}
+
+func summarizeSessions(task scheduler.Task, sessions []workSession, now time.Time) timerSummary {
+	summary := timerSummary{Sessions: sessions, EstimatedMinutes: int(math.Round(task.EstimatedHours * 60))}
+	var worked time.Duration
+	for _, session := range sessions {
+		end := now
+		if session.EndedAt != nil {
+			end = *session.EndedAt
+		} else {
+			summary.Running = true
+		}
+		if end.After(session.StartedAt) {
+			worked += end.Sub(session.StartedAt)
+		}
+	}
+	summary.ActualMinutes = int(math.Round(worked.Minutes()))
+	if summary.EstimatedMinutes > 0 {
+		summary.OverrunMinutes = summary.ActualMinutes - summary.EstimatedMinutes
+	}
+	return summary
+}
+
+func isRunning(sessions []workSession) bool {
+	for _, session := range sessions {
+		if session.EndedAt == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cal-enderBE/internal/calendars"
+	"cal-enderBE/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type writebackStats struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+type writebackState struct {
+	LastSyncedAt *string `json:"last_synced_at"`
+	LastError    *string `json:"last_error"`
+}
+
+type writebackQueue struct {
+	mu      sync.Mutex
+	running bool
+	pending bool
+	loc     *time.Location
+}
+
+type taskBlock struct {
+	TaskID     string `json:"task_id"`
+	Provider   string `json:"provider"`
+	CalendarID string `json:"calendar_id"`
+	ExternalID string `json:"external_id"`
+	TaskDate   string `json:"task_date"`
+	StartTime  string `json:"start_time"`
+	EndTime    string `json:"end_time"`
+	Title      string `json:"title"`
+}
+
+func (a *App) GetWriteBack(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	provider, calendarID, err := a.writebackTarget(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	status, err := a.writebackStatus(userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled":        provider != "",
+		"provider":       provider,
+		"calendar_id":    calendarID,
+		"last_synced_at": status.LastSyncedAt,
+		"last_error":     status.LastError,
+		"pending":        a.writeBackPending(userID),
+	})
+}
+
+func (a *App) EnableWriteBack(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	name := chi.URLParam(r, "provider")
+	var payload struct {
+		CalendarID string `json:"calendar_id"`
+	}
+	json.NewDecoder(r.Body).Decode(&payload)
+	if payload.CalendarID == "" {
+		payload.CalendarID = "primary"
+	}
+	if name == "ics" {
+		http.Error(w, "ics calendars are read-only", http.StatusBadRequest)
+		return
+	}
+	loc := a.userLocation(userID)
+	if _, err := a.calendarProvider(userID, name, "", loc); err != nil {
+		calendarError(w, name, err)
+		return
+	}
+	_, err := a.Supabase.UpsertOn("calendar_writeback", "user_id", map[string]any{
+		"user_id":     userID,
+		"provider":    name,
+		"calendar_id": payload.CalendarID,
+		"updated_at":  time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	stats, err := a.syncTaskBlocks(userID, loc)
+	a.recordWriteBack(userID, err)
+	if err != nil {
+		calendarError(w, name, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (a *App) DisableWriteBack(w http.ResponseWriter, r *http.Request) {
+	userID := userIDFromContext(r)
+	unlock := a.lockWriteBack(userID)
+	defer unlock()
+	filter := fmt.Sprintf("user_id=eq.%s", userID)
+	if err := a.Supabase.Delete("calendar_writeback", filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	blocks, err := a.loadTaskBlocks(userID, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if err := a.removeTaskBlocks(userID, blocks); err != nil {
+		calendarError(w, "calendar", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, writebackStats{Deleted: len(blocks)})
+}
+
+func (a *App) syncTaskBlocks(userID string, loc *time.Location) (writebackStats, error) {
+	unlock := a.lockWriteBack(userID)
+	defer unlock()
+	stats := writebackStats{}
+	name, calendarID, err := a.writebackTarget(userID)
+	if err != nil || name == "" {
+		return stats, err
+	}
+	today := time.Now().In(loc).Format("2006-01-02")
+	query := url.Values{}
+	query.Set("select", "*")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("task_date", fmt.Sprintf("gte.%s", today))
+	query.Set("or", "(status.is.null,status.neq.completed)")
+	query.Set("start_time", "not.is.null")
+	query.Set("end_time", "not.is.null")
+	tasks, err := a.loadTasks(query)
+	if err != nil {
+		return stats, err
+	}
+	existing, err := a.loadTaskBlocks(userID, nil)
+	if err != nil {
+		return stats, err
+	}
+	blocks := map[string]taskBlock{}
+	for _, block := range existing {
+		blocks[block.TaskID] = block
+	}
+	provider, err := a.calendarProvider(userID, name, "", loc)
+	if err != nil {
+		return stats, err
+	}
+	moved := []taskBlock{}
+	scheduled := map[string]bool{}
+	for _, task := range tasks {
+		scheduled[task.ID] = true
+		want := taskBlock{
+			TaskID:     task.ID,
+			Provider:   name,
+			CalendarID: calendarID,
+			TaskDate:   task.TaskDate,
+			StartTime:  blockTime(*task.StartTime),
+			EndTime:    blockTime(*task.EndTime),
+			Title:      task.Title,
+		}
+		current, ok := blocks[task.ID]
+		if ok && current.Provider == name && current.CalendarID == calendarID {
+			want.ExternalID = current.ExternalID
+			if current == want {
+				continue
+			}
+		} else if ok {
+			moved = append(moved, current)
+		}
+		event := calendars.Event{
+			ExternalID: want.ExternalID,
+			Title:      want.Title,
+			Start:      feedTime(want.TaskDate, scheduler.ToMinutes(want.StartTime), loc),
+			End:        feedTime(want.TaskDate, scheduler.ToMinutes(want.EndTime), loc),
+		}
+		written, err := provider.WriteEvent(calendarID, event)
+		if errors.Is(err, calendars.ErrNotFound) && event.ExternalID != "" {
+			event.ExternalID = ""
+			written, err = provider.WriteEvent(calendarID, event)
+		}
+		if err != nil {
+			return stats, err
+		}
+		if event.ExternalID == "" {
+			stats.Created++
+		} else {
+			stats.Updated++
+		}
+		want.ExternalID = written.ExternalID
+		if err := a.saveTaskBlock(userID, want); err != nil {
+			return stats, err
+		}
+	}
+	stale := []taskBlock{}
+	for _, block := range existing {
+		if !scheduled[block.TaskID] && block.TaskDate >= today {
+			stale = append(stale, block)
+		}
+	}
+	if err := a.deleteBlockEvents(userID, moved); err != nil {
+		return stats, err
+	}
+	if err := a.removeTaskBlocks(userID, stale); err != nil {
+		return stats, err
+	}
+	stats.Deleted = len(stale)
+	return stats, nil
+}
+
+func (a *App) writeBackAfterSave(userID string, loc *time.Location) {
+	value, _ := a.writebackQueues.LoadOrStore(userID, &writebackQueue{})
+	queue := value.(*writebackQueue)
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.loc = loc
+	if queue.running {
+		queue.pending = true
+		return
+	}
+	queue.running = true
+	go a.runWriteBack(userID, queue)
+}
+
+func (a *App) runWriteBack(userID string, queue *writebackQueue) {
+	for {
+		queue.mu.Lock()
+		loc := queue.loc
+		queue.mu.Unlock()
+		_, err := a.syncTaskBlocks(userID, loc)
+		a.recordWriteBack(userID, err)
+		queue.mu.Lock()
+		if !queue.pending {
+			queue.running = false
+			queue.mu.Unlock()
+			return
+		}
+		queue.pending = false
+		queue.mu.Unlock()
+	}
+}
+
+func (a *App) writeBackPending(userID string) bool {
+	value, ok := a.writebackQueues.Load(userID)
+	if !ok {
+		return false
+	}
+	queue := value.(*writebackQueue)
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return queue.running
+}
+
+func (a *App) recordWriteBack(userID string, syncErr error) {
+	payload := map[string]any{"last_error": nil, "last_synced_at": time.Now().UTC().Format(time.RFC3339)}
+	if syncErr != nil {
+		log.Printf("writeback: user %s: %v", userID, syncErr)
+		payload = map[string]any{"last_error": syncErr.Error()}
+	}
+	if _, err := a.Supabase.Update("calendar_writeback", fmt.Sprintf("user_id=eq.%s", userID), payload); err != nil {
+		log.Printf("writeback: user %s: %v", userID, err)
+	}
+}
+
+func (a *App) lockWriteBack(userID string) func() {
+	value, _ := a.writebackLocks.LoadOrStore(userID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (a *App) removeTaskBlocksFor(userID string, taskIDs []string) {
+	unlock := a.lockWriteBack(userID)
+	defer unlock()
+	blocks, err := a.loadTaskBlocks(userID, taskIDs)
+	if err == nil {
+		err = a.removeTaskBlocks(userID, blocks)
+	}
+	if err != nil {
+		log.Printf("writeback: user %s: %v", userID, err)
+	}
+}
+
+func (a *App) removeTaskBlocks(userID string, blocks []taskBlock) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	if err := a.deleteBlockEvents(userID, blocks); err != nil {
+		return err
+	}
+	taskIDs := make([]string, len(blocks))
+	for i, block := range blocks {
+		taskIDs[i] = block.TaskID
+	}
+	filter := fmt.Sprintf("user_id=eq.%s&task_id=in.(%s)", userID, strings.Join(taskIDs, ","))
+	return a.Supabase.Delete("task_calendar_blocks", filter)
+}
+
+func (a *App) deleteBlockEvents(userID string, blocks []taskBlock) error {
+	providers := map[string]calendars.Provider{}
+	for _, block := range blocks {
+		provider, ok := providers[block.Provider]
+		if !ok {
+			var err error
+			if provider, err = a.calendarProvider(userID, block.Provider, "", nil); err != nil {
+				return err
+			}
+			providers[block.Provider] = provider
+		}
+		err := provider.DeleteEvent(block.CalendarID, block.ExternalID)
+		if err != nil && !errors.Is(err, calendars.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *App) writebackTarget(userID string) (string, string, error) {
+	query := url.Values{}
+	query.Set("select", "provider,calendar_id")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	data, err := a.Supabase.Select("calendar_writeback", query)
+	if err != nil {
+		return "", "", err
+	}
+	var rows []struct {
+		Provider   string `json:"provider"`
+		CalendarID string `json:"calendar_id"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return "", "", err
+	}
+	if len(rows) == 0 {
+		return "", "", nil
+	}
+	return rows[0].Provider, rows[0].CalendarID, nil
+}
+
+func (a *App) writebackStatus(userID string) (writebackState, error) {
+	query := url.Values{}
+	query.Set("select", "last_synced_at,last_error")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	data, err := a.Supabase.Select("calendar_writeback", query)
+	if err != nil {
+		return writebackState{}, err
+	}
+	var rows []writebackState
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return writebackState{}, err
+	}
+	if len(rows) == 0 {
+		return writebackState{}, nil
+	}
+	return rows[0], nil
+}
+
+func (a *App) loadTaskBlocks(userID string, taskIDs []string) ([]taskBlock, error) {
+	query := url.Values{}
+	query.Set("select", "task_id,provider,calendar_id,external_id,task_date,start_time,end_time,title")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	if taskIDs != nil {
+		query.Set("task_id", fmt.Sprintf("in.(%s)", strings.Join(taskIDs, ",")))
+	}
+	data, err := a.Supabase.Select("task_calendar_blocks", query)
+	if err != nil {
+		return nil, err
+	}
+	blocks := []taskBlock{}
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return nil, err
+	}
+	for i := range blocks {
+		blocks[i].StartTime = blockTime(blocks[i].StartTime)
+		blocks[i].EndTime = blockTime(blocks[i].EndTime)
+	}
+	return blocks, nil
+}
+
+func (a *App) saveTaskBlock(userID string, block taskBlock) error {
+	_, err := a.Supabase.UpsertOn("task_calendar_blocks", "user_id,task_id", map[string]any{
+		"user_id":     userID,
+		"task_id":     block.TaskID,
+		"provider":    block.Provider,
+		"calendar_id": block.CalendarID,
+		"external_id": block.ExternalID,
+		"task_date":   block.TaskDate,
+		"start_time":  block.StartTime,
+		"end_time":    block.EndTime,
+		"title":       block.Title,
+		"updated_at":  time.Now().UTC().Format(time.RFC3339),
+	})
+	return err
+}
+
+func (a *App) ownExternalIDs(userID, provider string) map[string]bool {
+	query := url.Values{}
+	query.Set("select", "external_id")
+	query.Set("user_id", fmt.Sprintf("eq.%s", userID))
+	query.Set("provider", fmt.Sprintf("eq.%s", provider))
+	data, err := a.Supabase.Select("task_calendar_blocks", query)
+	own := map[string]bool{}
+	if err != nil {
+		return own
+	}
+	var rows []struct {
+		ExternalID string `json:"external_id"`
+	}
+	json.Unmarshal(data, &rows)
+	for _, row := range rows {
+		own[row.ExternalID] = true
+	}
+	return own
+}
+
+func blockTime(value string) string {
+	if len(value) > 5 {
+		return value[:5]
+	}
+	return value
+}
@@ -0,0 +1,52 @@
+package holidays
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name"`
+}
+
+//go:embed holidays.json
+var data []byte
+
+var byCountry map[string][]Holiday
+
+func init() {
+	if err := json.Unmarshal(data, &byCountry); err != nil {
+		panic(fmt.Sprintf("holidays: invalid data file: %v", err))
+	}
+}
+
+func Countries() []string {
+	countries := make([]string, 0, len(byCountry))
+	for country := range byCountry {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+func ForYear(country string, year int) ([]Holiday, error) {
+	list, ok := byCountry[strings.ToUpper(country)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported country %q (supported: %s)", country, strings.Join(Countries(), ", "))
+	}
+	prefix := fmt.Sprintf("%04d-", year)
+	out := []Holiday{}
+	for _, holiday := range list {
+		if strings.HasPrefix(holiday.Date, prefix) {
+			out = append(out, holiday)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no holiday data for %s in %d", strings.ToUpper(country), year)
+	}
+	return out, nil
+}
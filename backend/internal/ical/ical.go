@@ -0,0 +1,291 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type Event struct {
+	UID          string
+	Summary      string
+	Status       string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	RecurrenceID string
+	Props        []Property
+}
+
+func Parse(data string, loc *time.Location) ([]Event, error) {
+	events := []Event{}
+	var current *Event
+	depth := 0
+	for _, line := range unfold(data) {
+		prop, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VEVENT"):
+			current = &Event{}
+			depth = 0
+		case prop.Name == "BEGIN" && current != nil:
+			depth++
+		case prop.Name == "END" && current != nil && depth > 0:
+			depth--
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VEVENT") && current != nil:
+			event, err := finishEvent(*current, loc)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+			current = nil
+		case current != nil && depth == 0:
+			current.Props = append(current.Props, prop)
+		}
+	}
+	return events, nil
+}
+
+func (e Event) Prop(name string) (Property, bool) {
+	for _, prop := range e.Props {
+		if prop.Name == name {
+			return prop, true
+		}
+	}
+	return Property{}, false
+}
+
+func (e Event) PropsNamed(name string) []Property {
+	out := []Property{}
+	for _, prop := range e.Props {
+		if prop.Name == name {
+			out = append(out, prop)
+		}
+	}
+	return out
+}
+
+func finishEvent(event Event, loc *time.Location) (Event, error) {
+	if prop, ok := event.Prop("UID"); ok {
+		event.UID = prop.Value
+	}
+	if prop, ok := event.Prop("SUMMARY"); ok {
+		event.Summary = unescape(prop.Value)
+	}
+	if prop, ok := event.Prop("STATUS"); ok {
+		event.Status = strings.ToUpper(prop.Value)
+	}
+	if prop, ok := event.Prop("RECURRENCE-ID"); ok {
+		recurrence, allDay, err := ParseDateTime(prop, loc)
+		if err != nil {
+			return Event{}, err
+		}
+		event.RecurrenceID = occurrenceKey(recurrence, allDay)
+	}
+	startProp, ok := event.Prop("DTSTART")
+	if !ok {
+		return Event{}, fmt.Errorf("ical: event %q has no DTSTART", event.UID)
+	}
+	start, allDay, err := ParseDateTime(startProp, loc)
+	if err != nil {
+		return Event{}, err
+	}
+	event.Start = start
+	event.AllDay = allDay
+	if endProp, ok := event.Prop("DTEND"); ok {
+		end, _, err := ParseDateTime(endProp, loc)
+		if err != nil {
+			return Event{}, err
+		}
+		event.End = end
+	} else if durationProp, ok := event.Prop("DURATION"); ok {
+		duration, err := ParseDuration(durationProp.Value)
+		if err != nil {
+			return Event{}, err
+		}
+		event.End = start.Add(duration)
+	} else if allDay {
+		event.End = start.AddDate(0, 0, 1)
+	} else {
+		event.End = start
+	}
+	return event, nil
+}
+
+func ParseDateTime(prop Property, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(prop.Value)
+	if strings.EqualFold(prop.Params["VALUE"], "DATE") || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	source := loc
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(strings.Trim(tzid, `"`)); err == nil {
+			source = tz
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, source)
+	return t, false, err
+}
+
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	sign := time.Duration(1)
+	if strings.HasPrefix(value, "-") {
+		sign = -1
+	}
+	value = strings.TrimLeft(value, "+-")
+	if !strings.HasPrefix(value, "P") {
+		return 0, fmt.Errorf("ical: invalid duration %q", value)
+	}
+	var total time.Duration
+	inTime := false
+	number := ""
+	for _, r := range value[1:] {
+		switch {
+		case r >= '0' && r <= '9':
+			number += string(r)
+		case r == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, fmt.Errorf("ical: invalid duration %q", value)
+			}
+			number = ""
+			switch {
+			case r == 'W':
+				total += time.Duration(n) * 7 * 24 * time.Hour
+			case r == 'D':
+				total += time.Duration(n) * 24 * time.Hour
+			case r == 'H' && inTime:
+				total += time.Duration(n) * time.Hour
+			case r == 'M' && inTime:
+				total += time.Duration(n) * time.Minute
+			case r == 'S' && inTime:
+				total += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("ical: invalid duration %q", value)
+			}
+		}
+	}
+	return sign * total, nil
+}
+
+func unfold(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	lines := []string{}
+	for _, line := range strings.Split(data, "\n") {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func parseLine(line string) (Property, bool) {
+	colon := -1
+	inQuotes := false
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return Property{}, false
+	}
+	parts := strings.Split(line[:colon], ";")
+	prop := Property{
+		Name:   strings.ToUpper(strings.TrimSpace(parts[0])),
+		Params: map[string]string{},
+		Value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			prop.Params[strings.ToUpper(key)] = value
+		}
+	}
+	return prop, true
+}
+
+func unescape(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
+
+var managed = map[string]bool{
+	"UID": true, "DTSTAMP": true, "DTSTART": true, "DTEND": true, "DURATION": true, "SUMMARY": true, "STATUS": true,
+	"RRULE": true, "RDATE": true, "EXDATE": true, "RECURRENCE-ID": true,
+}
+
+func Marshal(name string, events []Event) string {
+	var b strings.Builder
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:-//cal-ender//EN")
+	if name != "" {
+		writeLine(&b, "X-WR-CALNAME:"+escape(name))
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, event := range events {
+		writeLine(&b, "BEGIN:VEVENT")
+		writeLine(&b, "UID:"+event.UID)
+		writeLine(&b, "DTSTAMP:"+stamp)
+		if event.AllDay {
+			writeLine(&b, "DTSTART;VALUE=DATE:"+event.Start.Format("20060102"))
+			writeLine(&b, "DTEND;VALUE=DATE:"+event.End.Format("20060102"))
+		} else {
+			writeLine(&b, "DTSTART:"+event.Start.UTC().Format("20060102T150405Z"))
+			writeLine(&b, "DTEND:"+event.End.UTC().Format("20060102T150405Z"))
+		}
+		writeLine(&b, "SUMMARY:"+escape(event.Summary))
+		if event.Status != "" {
+			writeLine(&b, "STATUS:"+event.Status)
+		}
+		for _, prop := range event.Props {
+			if !managed[prop.Name] {
+				writeLine(&b, prop.Name+":"+prop.Value)
+			}
+		}
+		writeLine(&b, "END:VEVENT")
+	}
+	writeLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+func writeLine(b *strings.Builder, line string) {
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
+
+func escape(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return replacer.Replace(value)
+}
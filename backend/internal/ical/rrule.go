@@ -0,0 +1,456 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxPeriods = 10000
+
+var ErrUnsupportedRule = errors.New("ical: unsupported RRULE part")
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+type WeekdayNum struct {
+	Day time.Weekday
+	N   int
+}
+
+type Rule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+func ParseRule(value string, loc *time.Location) (Rule, error) {
+	rule := Rule{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(value), "RRULE:"), ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		val = strings.ToUpper(val)
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = val
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+		case "UNTIL":
+			var dateOnly bool
+			rule.Until, dateOnly, err = ParseDateTime(Property{Value: val}, loc)
+			if dateOnly {
+				rule.Until = rule.Until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				if len(item) < 2 {
+					return Rule{}, fmt.Errorf("ical: invalid BYDAY %q", item)
+				}
+				day, ok := weekdays[item[len(item)-2:]]
+				if !ok {
+					return Rule{}, fmt.Errorf("ical: invalid BYDAY %q", item)
+				}
+				n := 0
+				if prefix := item[:len(item)-2]; prefix != "" {
+					if n, err = strconv.Atoi(prefix); err != nil {
+						return Rule{}, fmt.Errorf("ical: invalid BYDAY %q", item)
+					}
+				}
+				rule.ByDay = append(rule.ByDay, WeekdayNum{Day: day, N: n})
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseInts(val)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(val)
+			for _, month := range months {
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		case "BYSETPOS":
+			rule.BySetPos, err = parseInts(val)
+		case "WKST":
+			day, ok := weekdays[val]
+			if !ok {
+				return Rule{}, fmt.Errorf("ical: invalid WKST %q", val)
+			}
+			rule.WeekStart = day
+		case "BYSECOND", "BYMINUTE", "BYHOUR", "BYWEEKNO", "BYYEARDAY":
+			return Rule{}, fmt.Errorf("%w: %s in %q", ErrUnsupportedRule, strings.ToUpper(key), value)
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("ical: invalid %s in %q", key, value)
+		}
+	}
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return Rule{}, fmt.Errorf("ical: unsupported FREQ %q", rule.Freq)
+	}
+	if rule.Interval < 1 {
+		rule.Interval = 1
+	}
+	return rule, nil
+}
+
+func (r Rule) Between(start, from, to time.Time) []time.Time {
+	out := []time.Time{}
+	count := 0
+	first := r.firstPeriod(start, from)
+	for period := first; period < first+maxPeriods; period++ {
+		candidates, periodStart := r.candidates(start, period)
+		if !periodStart.Before(to) {
+			return out
+		}
+		for _, t := range candidates {
+			if t.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return out
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return out
+			}
+			if !t.Before(to) {
+				return out
+			}
+			if !t.Before(from) {
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+// firstPeriod skips the periods that end before from, so an old DTSTART
+// doesn't use up maxPeriods before reaching the window. COUNT needs every
+// earlier occurrence counted, so those rules always start at zero. One period
+// of slack covers week alignment and months shorter than the start day.
+func (r Rule) firstPeriod(start, from time.Time) int {
+	if r.Count > 0 || !from.After(start) {
+		return 0
+	}
+	from = from.In(start.Location())
+	elapsed := 0
+	switch r.Freq {
+	case "DAILY":
+		elapsed = daysBetween(start, from)
+	case "WEEKLY":
+		elapsed = daysBetween(start, from) / 7
+	case "MONTHLY":
+		elapsed = (from.Year()-start.Year())*12 + int(from.Month()) - int(start.Month())
+	case "YEARLY":
+		elapsed = from.Year() - start.Year()
+	}
+	if skip := elapsed/r.Interval - 1; skip > 0 {
+		return skip
+	}
+	return 0
+}
+
+func (r Rule) candidates(start time.Time, period int) ([]time.Time, time.Time) {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+	days := []time.Time{}
+	var periodStart time.Time
+	switch r.Freq {
+	case "DAILY":
+		day := at(start.Year(), start.Month(), start.Day()+period*r.Interval)
+		periodStart = day
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			days = append(days, day)
+		}
+	case "WEEKLY":
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		periodStart = at(start.Year(), start.Month(), start.Day()-offset+period*r.Interval*7)
+		for i := 0; i < 7; i++ {
+			day := at(periodStart.Year(), periodStart.Month(), periodStart.Day()+i)
+			if len(r.ByDay) == 0 && day.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matchesWeekday(day) && r.matchesMonth(day) {
+				days = append(days, day)
+			}
+		}
+	case "MONTHLY":
+		periodStart = at(start.Year(), start.Month()+time.Month(period*r.Interval), 1)
+		if r.matchesMonth(periodStart) {
+			days = r.monthDays(start, periodStart, at)
+		}
+	case "YEARLY":
+		year := start.Year() + period*r.Interval
+		periodStart = at(year, time.January, 1)
+		months := r.ByMonth
+		switch {
+		case len(months) > 0:
+		case len(r.ByDay) > 0 && len(r.ByMonthDay) == 0:
+			days = r.yearDays(year, at)
+		case len(r.ByMonthDay) > 0:
+			for month := time.January; month <= time.December; month++ {
+				months = append(months, month)
+			}
+		default:
+			months = []time.Month{start.Month()}
+		}
+		for _, month := range months {
+			days = append(days, r.monthDays(start, at(year, month, 1), at)...)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return r.applySetPos(days), periodStart
+}
+
+func (r Rule) monthDays(start, first time.Time, at func(int, time.Month, int) time.Time) []time.Time {
+	year, month := first.Year(), first.Month()
+	last := at(year, month+1, 0).Day()
+	days := []time.Time{}
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, n := range r.ByMonthDay {
+			day := n
+			if n < 0 {
+				day = last + n + 1
+			}
+			if day >= 1 && day <= last {
+				if t := at(year, month, day); r.matchesWeekday(t) {
+					days = append(days, t)
+				}
+			}
+		}
+	case len(r.ByDay) > 0:
+		for _, spec := range r.ByDay {
+			matches := []time.Time{}
+			for day := 1; day <= last; day++ {
+				if t := at(year, month, day); t.Weekday() == spec.Day {
+					matches = append(matches, t)
+				}
+			}
+			switch {
+			case spec.N > 0 && spec.N <= len(matches):
+				days = append(days, matches[spec.N-1])
+			case spec.N < 0 && -spec.N <= len(matches):
+				days = append(days, matches[len(matches)+spec.N])
+			case spec.N == 0:
+				days = append(days, matches...)
+			}
+		}
+	default:
+		if start.Day() <= last {
+			days = append(days, at(year, month, start.Day()))
+		}
+	}
+	return days
+}
+
+// yearDays expands BYDAY over a whole year, as YEARLY rules without BYMONTH
+// do: 20MO is the year's twentieth Monday and MO is every Monday.
+func (r Rule) yearDays(year int, at func(int, time.Month, int) time.Time) []time.Time {
+	days := []time.Time{}
+	for _, spec := range r.ByDay {
+		matches := []time.Time{}
+		for t := at(year, time.January, 1); t.Year() == year; t = at(year, time.January, t.YearDay()+1) {
+			if t.Weekday() == spec.Day {
+				matches = append(matches, t)
+			}
+		}
+		switch {
+		case spec.N > 0 && spec.N <= len(matches):
+			days = append(days, matches[spec.N-1])
+		case spec.N < 0 && -spec.N <= len(matches):
+			days = append(days, matches[len(matches)+spec.N])
+		case spec.N == 0:
+			days = append(days, matches...)
+		}
+	}
+	return days
+}
+
+func (r Rule) applySetPos(days []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 {
+		return days
+	}
+	out := []time.Time{}
+	for _, pos := range r.BySetPos {
+		switch {
+		case pos > 0 && pos <= len(days):
+			out = append(out, days[pos-1])
+		case pos < 0 && -pos <= len(days):
+			out = append(out, days[len(days)+pos])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
+
+func (r Rule) matchesMonth(t time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range r.ByMonth {
+		if t.Month() == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, n := range r.ByMonthDay {
+		if n == t.Day() || (n < 0 && last+n+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, spec := range r.ByDay {
+		if spec.Day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func Expand(events []Event, from, to time.Time) ([]Event, error) {
+	overrides := map[string]map[string]bool{}
+	for _, event := range events {
+		if event.RecurrenceID == "" {
+			continue
+		}
+		if overrides[event.UID] == nil {
+			overrides[event.UID] = map[string]bool{}
+		}
+		overrides[event.UID][event.RecurrenceID] = true
+	}
+	out := []Event{}
+	for _, event := range events {
+		if event.RecurrenceID != "" {
+			if overlaps(event, from, to) {
+				out = append(out, event)
+			}
+			continue
+		}
+		rules := event.PropsNamed("RRULE")
+		rdates := event.PropsNamed("RDATE")
+		if len(rules) == 0 && len(rdates) == 0 {
+			if overlaps(event, from, to) {
+				out = append(out, event)
+			}
+			continue
+		}
+		excluded := map[string]bool{}
+		for _, prop := range event.PropsNamed("EXDATE") {
+			for _, t := range listDates(prop, event.Start.Location()) {
+				excluded[occurrenceKey(t, event.AllDay)] = true
+			}
+		}
+		starts := []time.Time{}
+		windowStart := from.Add(-event.End.Sub(event.Start))
+		for _, prop := range rules {
+			rule, err := ParseRule(prop.Value, event.Start.Location())
+			if err != nil {
+				return nil, err
+			}
+			starts = append(starts, rule.Between(event.Start, windowStart, to)...)
+		}
+		if len(rules) == 0 {
+			starts = append(starts, event.Start)
+		}
+		for _, prop := range rdates {
+			starts = append(starts, listDates(prop, event.Start.Location())...)
+		}
+		seen := map[string]bool{}
+		for _, start := range starts {
+			key := occurrenceKey(start, event.AllDay)
+			if seen[key] || excluded[key] || overrides[event.UID][key] {
+				continue
+			}
+			seen[key] = true
+			occurrence := event
+			occurrence.Start = start
+			occurrence.RecurrenceID = key
+			if event.AllDay {
+				occurrence.End = start.AddDate(0, 0, daysBetween(event.Start, event.End))
+			} else {
+				occurrence.End = start.Add(event.End.Sub(event.Start))
+			}
+			if overlaps(occurrence, from, to) {
+				out = append(out, occurrence)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+func occurrenceKey(t time.Time, allDay bool) string {
+	if allDay {
+		return t.Format("20060102")
+	}
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func overlaps(event Event, from, to time.Time) bool {
+	if !event.Start.Before(to) {
+		return false
+	}
+	if event.End.After(event.Start) {
+		return event.End.After(from)
+	}
+	return !event.Start.Before(from)
+}
+
+func listDates(prop Property, loc *time.Location) []time.Time {
+	out := []time.Time{}
+	for _, value := range strings.Split(prop.Value, ",") {
+		item := Property{Name: prop.Name, Params: prop.Params, Value: value}
+		if t, _, err := ParseDateTime(item, loc); err == nil {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func daysBetween(start, end time.Time) int {
+	a := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func parseInts(value string) ([]int, error) {
+	out := []int{}
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
@@ -0,0 +1,152 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleBetween(t *testing.T) {
+	date := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse("2006-01-02T15:04", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		from  string
+		to    string
+		want  []string
+	}{
+		{
+			name:  "daily from an old start",
+			rule:  "FREQ=DAILY",
+			start: "1980-01-01T09:00",
+			from:  "2026-10-19T00:00",
+			to:    "2026-10-22T00:00",
+			want:  []string{"2026-10-19", "2026-10-20", "2026-10-21"},
+		},
+		{
+			name:  "daily interval keeps its phase after the jump",
+			rule:  "FREQ=DAILY;INTERVAL=3",
+			start: "1990-01-01T09:00",
+			from:  "2026-10-19T00:00",
+			to:    "2026-10-26T00:00",
+			want:  []string{"2026-10-19", "2026-10-22", "2026-10-25"},
+		},
+		{
+			name:  "weekly by day",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE",
+			start: "2026-10-05T10:00",
+			from:  "2026-10-19T00:00",
+			to:    "2026-10-26T00:00",
+			want:  []string{"2026-10-19", "2026-10-21"},
+		},
+		{
+			name:  "weekly interval with monday week start",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			start: "1997-08-05T09:00",
+			from:  "1997-08-01T00:00",
+			to:    "1997-09-30T00:00",
+			want:  []string{"1997-08-05", "1997-08-10", "1997-08-19", "1997-08-24"},
+		},
+		{
+			name:  "weekly interval with sunday week start",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			start: "1997-08-05T09:00",
+			from:  "1997-08-01T00:00",
+			to:    "1997-09-30T00:00",
+			want:  []string{"1997-08-05", "1997-08-17", "1997-08-19", "1997-08-31"},
+		},
+		{
+			name:  "monthly last friday with count",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			start: "2026-10-30T15:00",
+			from:  "2026-10-01T00:00",
+			to:    "2027-06-01T00:00",
+			want:  []string{"2026-10-30", "2026-11-27", "2026-12-25"},
+		},
+		{
+			name:  "monthly from an old start",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=15",
+			start: "1950-03-15T09:00",
+			from:  "2026-10-01T00:00",
+			to:    "2026-12-01T00:00",
+			want:  []string{"2026-10-15", "2026-11-15"},
+		},
+		{
+			name:  "yearly by day without by month covers the whole year",
+			rule:  "FREQ=YEARLY;BYDAY=MO",
+			start: "2026-01-05T09:00",
+			from:  "2026-10-01T00:00",
+			to:    "2026-10-20T00:00",
+			want:  []string{"2026-10-05", "2026-10-12", "2026-10-19"},
+		},
+		{
+			name:  "yearly numbered weekday of the year",
+			rule:  "FREQ=YEARLY;BYDAY=20MO",
+			start: "1997-05-19T09:00",
+			from:  "1997-01-01T00:00",
+			to:    "1999-12-31T00:00",
+			want:  []string{"1997-05-19", "1998-05-18", "1999-05-17"},
+		},
+		{
+			name:  "yearly by month day in every month",
+			rule:  "FREQ=YEARLY;BYMONTHDAY=13;BYDAY=FR",
+			start: "2026-02-13T09:00",
+			from:  "2026-01-01T00:00",
+			to:    "2027-01-01T00:00",
+			want:  []string{"2026-02-13", "2026-03-13", "2026-11-13"},
+		},
+		{
+			name:  "until is inclusive",
+			rule:  "FREQ=DAILY;UNTIL=20261021",
+			start: "2026-10-19T09:00",
+			from:  "2026-10-01T00:00",
+			to:    "2026-11-01T00:00",
+			want:  []string{"2026-10-19", "2026-10-20", "2026-10-21"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRule(test.rule, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, day := range rule.Between(date(test.start), date(test.from), date(test.to)) {
+				got = append(got, day.Format("2006-01-02"))
+			}
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseRuleRejectsUnsupportedParts(t *testing.T) {
+	for _, value := range []string{
+		"FREQ=DAILY;BYHOUR=9,17",
+		"FREQ=YEARLY;BYWEEKNO=20",
+		"FREQ=YEARLY;BYYEARDAY=100",
+		"FREQ=HOURLY",
+	} {
+		_, err := ParseRule(value, time.UTC)
+		if err == nil {
+			t.Errorf("%s: parsed, want an error", value)
+			continue
+		}
+		if strings.HasPrefix(value, "FREQ=HOURLY") {
+			continue
+		}
+		if !errors.Is(err, ErrUnsupportedRule) {
+			t.Errorf("%s: got %v, want ErrUnsupportedRule", value, err)
+		}
+	}
+}
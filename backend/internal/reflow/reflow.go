@@ -0,0 +1,59 @@
+package reflow
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"cal-enderBE/internal/scheduler"
+)
+
+type Target interface {
+	ActiveUserIDs() ([]string, error)
+	Reflow(userID string, now time.Time) (scheduler.ScheduleResult, error)
+}
+
+type Runner struct {
+	Target   Target
+	Interval time.Duration
+}
+
+func NewRunner(target Target) *Runner {
+	return &Runner{Target: target, Interval: time.Hour}
+}
+
+func (r *Runner) Start(ctx context.Context) {
+	go r.loop(ctx)
+}
+
+func (r *Runner) loop(ctx context.Context) {
+	timer := time.NewTimer(time.Until(time.Now().Truncate(r.Interval).Add(r.Interval)))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-timer.C:
+			r.RunOnce(now)
+			timer.Reset(time.Until(time.Now().Truncate(r.Interval).Add(r.Interval)))
+		}
+	}
+}
+
+func (r *Runner) RunOnce(now time.Time) {
+	userIDs, err := r.Target.ActiveUserIDs()
+	if err != nil {
+		log.Printf("reflow: list users: %v", err)
+		return
+	}
+	for _, userID := range userIDs {
+		result, err := r.Target.Reflow(userID, now)
+		if err != nil {
+			log.Printf("reflow: user %s: %v", userID, err)
+			continue
+		}
+		if len(result.Updates) > 0 || len(result.Inserts) > 0 {
+			log.Printf("reflow: user %s: updated %d, inserted %d", userID, len(result.Updates), len(result.Inserts))
+		}
+	}
+}
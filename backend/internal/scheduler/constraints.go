@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+type TimeWindow struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type ConstraintViolation struct {
+	ID               string `json:"id"`
+	Title            string `json:"title"`
+	Reason           string `json:"reason"`
+	RemainingMinutes int    `json:"remaining_minutes"`
+}
+
+func (t Task) availableOn(date string) bool {
+	if t.EarliestDate != nil && *t.EarliestDate != "" && date < *t.EarliestDate {
+		return false
+	}
+	if t.LatestDate != nil && *t.LatestDate != "" && date > *t.LatestDate {
+		return false
+	}
+	if len(t.AllowedWeekdays) == 0 {
+		return true
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return false
+	}
+	key := strings.ToLower(day.Weekday().String()[:3])
+	for _, allowed := range t.AllowedWeekdays {
+		if len(allowed) >= 3 && strings.ToLower(allowed[:3]) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Task) windows() [][2]int {
+	windows := [][2]int{}
+	for _, window := range t.AllowedWindows {
+		start, end := toMinutes(window.Start), toMinutes(window.End)
+		if end > start {
+			windows = append(windows, [2]int{start, end})
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i][0] < windows[j][0] })
+	return windows
+}
+
+func (t Task) legalSpan(date string, from, to int) (int, int, bool) {
+	if !t.availableOn(date) {
+		return 0, 0, false
+	}
+	if t.EarliestDate != nil && date == *t.EarliestDate && t.EarliestTime != nil && *t.EarliestTime != "" {
+		from = maxInt(from, toMinutes(*t.EarliestTime))
+	}
+	windows := t.windows()
+	if len(windows) == 0 {
+		return from, to, from < to
+	}
+	for _, window := range windows {
+		start, end := maxInt(from, window[0]), minInt(to, window[1])
+		if start < end {
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+func (t Task) constrained() bool {
+	return (t.EarliestDate != nil && *t.EarliestDate != "") || (t.LatestDate != nil && *t.LatestDate != "") ||
+		len(t.AllowedWeekdays) > 0 || len(t.AllowedWindows) > 0
+}
+
+func analyzeConstraints(tasks []Task, schedulableIDs map[string]bool, unplaced []Unplaced, settings Settings) []ConstraintViolation {
+	remaining := map[string]int{}
+	for _, item := range unplaced {
+		remaining[item.ID] = item.RemainingMinutes
+	}
+	violations := []ConstraintViolation{}
+	for _, task := range tasks {
+		if !task.constrained() || strings.EqualFold(task.Status, "completed") {
+			continue
+		}
+		reason := ""
+		switch {
+		case schedulableIDs[task.ID] && remaining[task.ID] > 0:
+			reason = unplacedReason(task, settings)
+		case !schedulableIDs[task.ID] && task.StartTime != nil && task.EndTime != nil:
+			start, end := toMinutes(*task.StartTime), toMinutes(*task.EndTime)
+			if legalStart, legalEnd, ok := task.legalSpan(task.TaskDate, start, end); !ok || legalStart != start || legalEnd != end {
+				reason = "scheduled outside the allowed days or hours"
+			}
+		}
+		if reason != "" {
+			violations = append(violations, ConstraintViolation{
+				ID:               task.ID,
+				Title:            task.Title,
+				Reason:           reason,
+				RemainingMinutes: remaining[task.ID],
+			})
+		}
+	}
+	return violations
+}
+
+func unplacedReason(task Task, settings Settings) string {
+	if task.EarliestDate != nil && task.DeadlineDate != nil && *task.DeadlineDate != "" && *task.EarliestDate > *task.DeadlineDate {
+		return "earliest start is after the deadline"
+	}
+	if task.EarliestDate != nil && task.LatestDate != nil && *task.LatestDate != "" && *task.EarliestDate > *task.LatestDate {
+		return "earliest start is after the latest date"
+	}
+	date := settings.StartDate
+	if task.EarliestDate != nil && *task.EarliestDate > date {
+		date = *task.EarliestDate
+	}
+	if date == "" {
+		date = task.TaskDate
+	}
+	if task.LatestDate != nil && *task.LatestDate != "" && date > *task.LatestDate {
+		return "latest date has passed"
+	}
+	for i := 0; i < 7; i++ {
+		for _, window := range settings.workWindows(addDays(date, i)) {
+			if _, _, ok := task.legalSpan(addDays(date, i), window[0], window[1]); ok {
+				return "no free time left in the allowed days and hours"
+			}
+		}
+	}
+	return "allowed days and hours never overlap working hours"
+}
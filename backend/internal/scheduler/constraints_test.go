@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFullWindowLeavesTheTaskUnplaced(t *testing.T) {
+	settings := Settings{
+		WorkStartMinutes: 540,
+		WorkEndMinutes:   1020,
+		BreakMinutes:     15,
+		Location:         time.UTC,
+		StartDate:        "2026-10-19",
+	}
+	events := []Event{}
+	for _, date := range []string{"2026-10-19", "2026-10-20", "2026-10-21"} {
+		events = append(events, Event{ID: "busy-" + date, Title: "Offsite", EventDate: date, StartTime: "09:00", EndTime: "17:00"})
+	}
+	// "Any time Mon–Wed": with those days full it must not slip to Thursday
+	// or to the next Monday.
+	task := Task{
+		ID:             "a",
+		Title:          "Weekly report",
+		TaskDate:       "2026-10-19",
+		EstimatedHours: 1,
+		DeadlineType:   strPtr("hard"),
+		DeadlineDate:   strPtr("2026-10-21"),
+		EarliestDate:   strPtr("2026-10-19"),
+		LatestDate:     strPtr("2026-10-21"),
+	}
+	solvers := map[string]func([]Task, []Event, Settings, string, bool) ScheduleResult{
+		"greedy":   AutoSchedule,
+		"deadline": DeadlineSchedule,
+	}
+	for name, solve := range solvers {
+		t.Run(name, func(t *testing.T) {
+			result := solve([]Task{task}, events, settings, "", false)
+			if len(result.Updates) != 0 || len(result.Inserts) != 0 {
+				t.Fatalf("got updates %+v and inserts %+v, want the task left unplaced", result.Updates, result.Inserts)
+			}
+			if len(result.Unplaced) != 1 || result.Unplaced[0].ID != "a" {
+				t.Fatalf("got unplaced %+v, want the task", result.Unplaced)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"sort"
+	"strings"
+)
+
+type Finish struct {
+	ID         string `json:"id"`
+	FinishDate string `json:"finish_date"`
+	FinishTime string `json:"finish_time"`
+}
+
+type DeadlineViolation struct {
+	ID           string   `json:"id"`
+	Title        string   `json:"title"`
+	DeadlineType string   `json:"deadline_type"`
+	DeadlineDate string   `json:"deadline_date"`
+	FinishDate   string   `json:"finish_date"`
+	MinutesShort int      `json:"minutes_short"`
+	CrowdedBy    []string `json:"crowded_by"`
+}
+
+type segment struct {
+	taskID string
+	date   string
+	start  int
+	end    int
+}
+
+func analyzeDeadlines(tasks []Task, schedulableIDs map[string]bool, segments []segment, unplaced []Unplaced) ([]Finish, []DeadlineViolation) {
+	segmentsByTask := map[string][]segment{}
+	for _, seg := range segments {
+		segmentsByTask[seg.taskID] = append(segmentsByTask[seg.taskID], seg)
+	}
+	unplacedMinutes := map[string]int{}
+	for _, item := range unplaced {
+		unplacedMinutes[item.ID] = item.RemainingMinutes
+	}
+	tasksByID := map[string]Task{}
+	for _, task := range tasks {
+		tasksByID[task.ID] = task
+	}
+
+	finishes := []Finish{}
+	violations := []DeadlineViolation{}
+	for _, task := range tasks {
+		if strings.EqualFold(task.Status, "completed") {
+			continue
+		}
+		finishDate, finishTime := "", ""
+		if schedulableIDs[task.ID] {
+			for _, seg := range segmentsByTask[task.ID] {
+				if seg.date > finishDate || (seg.date == finishDate && toTimeString(seg.end) > finishTime) {
+					finishDate = seg.date
+					finishTime = toTimeString(seg.end)
+				}
+			}
+		} else if task.StartTime != nil && task.EndTime != nil {
+			finishDate = task.TaskDate
+			finishTime = *task.EndTime
+		}
+		if finishDate != "" {
+			finishes = append(finishes, Finish{ID: task.ID, FinishDate: finishDate, FinishTime: finishTime})
+		}
+
+		if task.DeadlineDate == nil || *task.DeadlineDate == "" {
+			continue
+		}
+		deadline := *task.DeadlineDate
+		short := unplacedMinutes[task.ID]
+		if schedulableIDs[task.ID] {
+			for _, seg := range segmentsByTask[task.ID] {
+				if seg.date > deadline {
+					short += seg.end - seg.start
+				}
+			}
+		} else if finishDate > deadline && task.StartTime != nil && task.EndTime != nil {
+			short += toMinutes(*task.EndTime) - toMinutes(*task.StartTime)
+		}
+		if short <= 0 {
+			continue
+		}
+		deadlineType := "soft"
+		if task.DeadlineType != nil && *task.DeadlineType == "hard" {
+			deadlineType = "hard"
+		}
+		violations = append(violations, DeadlineViolation{
+			ID:           task.ID,
+			Title:        task.Title,
+			DeadlineType: deadlineType,
+			DeadlineDate: deadline,
+			FinishDate:   finishDate,
+			MinutesShort: short,
+			CrowdedBy:    crowdingTasks(task, segments, tasksByID),
+		})
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].DeadlineType != violations[j].DeadlineType {
+			return violations[i].DeadlineType == "hard"
+		}
+		return violations[i].DeadlineDate < violations[j].DeadlineDate
+	})
+	return finishes, violations
+}
+
+func crowdingTasks(task Task, segments []segment, tasksByID map[string]Task) []string {
+	deadline := *task.DeadlineDate
+	seen := map[string]bool{}
+	crowding := []string{}
+	for _, seg := range segments {
+		if seg.taskID == task.ID || seg.date > deadline || seen[seg.taskID] {
+			continue
+		}
+		other, ok := tasksByID[seg.taskID]
+		if !ok || !lessUrgent(other, task) {
+			continue
+		}
+		seen[seg.taskID] = true
+		crowding = append(crowding, seg.taskID)
+	}
+	return crowding
+}
+
+func lessUrgent(other, task Task) bool {
+	if other.DeadlineDate == nil || *other.DeadlineDate == "" {
+		return true
+	}
+	if *other.DeadlineDate != *task.DeadlineDate {
+		return *other.DeadlineDate > *task.DeadlineDate
+	}
+	otherHard := other.DeadlineType != nil && *other.DeadlineType == "hard"
+	taskHard := task.DeadlineType != nil && *task.DeadlineType == "hard"
+	return taskHard && !otherHard
+}
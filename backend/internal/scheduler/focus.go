@@ -0,0 +1,51 @@
+package scheduler
+
+const focusLookahead = 5
+
+func (s Settings) focusLevel(minute int) int {
+	if minute < 0 {
+		return 0
+	}
+	return s.FocusLevels[(minute/60)%24]
+}
+
+func focusDemand(task Task) int {
+	switch {
+	case task.PriorityLevel == 1 || task.EstimatedHours >= 2:
+		return 1
+	case task.PriorityLevel >= 3 || task.EstimatedHours <= 0.5:
+		return -1
+	}
+	return 0
+}
+
+func focusPick(tasks []Task, level int) int {
+	if level == 0 {
+		return 0
+	}
+	fallback := -1
+	for i, task := range tasks {
+		if waitsOn(task, tasks[:i]) {
+			continue
+		}
+		demand := focusDemand(task)
+		if demand == level {
+			return i
+		}
+		if fallback < 0 && demand != -level {
+			fallback = i
+		}
+	}
+	return maxInt(fallback, 0)
+}
+
+func waitsOn(task Task, earlier []Task) bool {
+	for _, other := range earlier {
+		for _, depID := range task.Dependencies {
+			if depID == other.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -9,111 +9,128 @@ import (
 )
 
 type Task struct {
-	ID             string   `json:"id"`
-	UserID         string   `json:"user_id"`
-	ProjectID      *string  `json:"project_id"`
-	Title          string   `json:"title"`
-	Company        string   `json:"company"`
-	Project        string   `json:"project"`
-	TaskDate       string   `json:"task_date"`
-	StartTime      *string  `json:"start_time"`
-	EndTime        *string  `json:"end_time"`
-	EstimatedHours float64  `json:"estimated_hours"`
-	PriorityLevel  int      `json:"priority_level"`
-	DeadlineType   *string  `json:"deadline_type"`
-	DeadlineDate   *string  `json:"deadline_date"`
-	Dependencies   []string `json:"dependencies"`
-	Status         string   `json:"status"`
-	Notes          *string  `json:"notes"`
+	ID              string       `json:"id"`
+	UserID          string       `json:"user_id"`
+	ProjectID       *string      `json:"project_id"`
+	Title           string       `json:"title"`
+	Company         string       `json:"company"`
+	Project         string       `json:"project"`
+	TaskDate        string       `json:"task_date"`
+	StartTime       *string      `json:"start_time"`
+	EndTime         *string      `json:"end_time"`
+	EstimatedHours  float64      `json:"estimated_hours"`
+	PriorityLevel   int          `json:"priority_level"`
+	DeadlineType    *string      `json:"deadline_type"`
+	DeadlineDate    *string      `json:"deadline_date"`
+	EarliestDate    *string      `json:"earliest_date"`
+	EarliestTime    *string      `json:"earliest_time"`
+	LatestDate      *string      `json:"latest_date"`
+	AllowedWindows  []TimeWindow `json:"allowed_windows"`
+	AllowedWeekdays []string     `json:"allowed_weekdays"`
+	Dependencies    []string     `json:"dependencies"`
+	Status          string       `json:"status"`
+	Notes           *string      `json:"notes"`
+	ParentTaskID    *string      `json:"parent_task_id"`
 }
 
 type Event struct {
-	ID        string `json:"id"`
-	UserID    string `json:"user_id"`
-	Title     string `json:"title"`
-	EventDate string `json:"event_date"`
-	StartTime string `json:"start_time"`
-	EndTime   string `json:"end_time"`
+	ID         string  `json:"id"`
+	UserID     string  `json:"user_id"`
+	Title      string  `json:"title"`
+	EventDate  string  `json:"event_date"`
+	EndDate    *string `json:"end_date"`
+	StartTime  string  `json:"start_time"`
+	EndTime    string  `json:"end_time"`
+	AllDay     bool    `json:"all_day"`
+	CalendarID *string `json:"calendar_id"`
 }
 
 type Settings struct {
 	WorkStartMinutes int
 	WorkEndMinutes   int
 	BreakMinutes     int
+	Availability     map[time.Weekday][][2]int
+	DaysOff          map[string]bool
+	Location         *time.Location
+	AllDayPolicy     string
+	CalendarPolicies map[string]string
+	StartDate        string
+	StartMinutes     int
+	FocusMatching    bool
+	FocusLevels      [24]int
 }
 
 type Update struct {
-	ID        string `json:"id"`
-	TaskDate  string `json:"task_date"`
-	StartTime string `json:"start_time"`
-	EndTime   string `json:"end_time"`
+	ID            string  `json:"id"`
+	Title         string  `json:"title"`
+	TaskDate      string  `json:"task_date"`
+	StartTime     string  `json:"start_time"`
+	EndTime       string  `json:"end_time"`
+	PrevTaskDate  string  `json:"prev_task_date"`
+	PrevStartTime *string `json:"prev_start_time"`
+	PrevEndTime   *string `json:"prev_end_time"`
 }
 
 type Insert struct {
-	UserID         string   `json:"user_id"`
-	Title          string   `json:"title"`
-	Company        string   `json:"company"`
-	Project        string   `json:"project"`
-	ProjectID      *string  `json:"project_id,omitempty"`
-	Notes          *string  `json:"notes,omitempty"`
-	TaskDate       string   `json:"task_date"`
-	StartTime      string   `json:"start_time"`
-	EndTime        string   `json:"end_time"`
-	IsMilestone    bool     `json:"is_milestone"`
-	EstimatedHours float64  `json:"estimated_hours"`
-	Status         string   `json:"status"`
-	Dependencies   []string `json:"dependencies"`
-	PriorityLevel  int      `json:"priority_level"`
-	DeadlineType   *string  `json:"deadline_type,omitempty"`
-	DeadlineDate   *string  `json:"deadline_date,omitempty"`
+	UserID          string       `json:"user_id"`
+	Title           string       `json:"title"`
+	Company         string       `json:"company"`
+	Project         string       `json:"project"`
+	ProjectID       *string      `json:"project_id,omitempty"`
+	Notes           *string      `json:"notes,omitempty"`
+	TaskDate        string       `json:"task_date"`
+	StartTime       string       `json:"start_time"`
+	EndTime         string       `json:"end_time"`
+	IsMilestone     bool         `json:"is_milestone"`
+	EstimatedHours  float64      `json:"estimated_hours"`
+	Status          string       `json:"status"`
+	Dependencies    []string     `json:"dependencies"`
+	PriorityLevel   int          `json:"priority_level"`
+	DeadlineType    *string      `json:"deadline_type,omitempty"`
+	DeadlineDate    *string      `json:"deadline_date,omitempty"`
+	EarliestDate    *string      `json:"earliest_date,omitempty"`
+	EarliestTime    *string      `json:"earliest_time,omitempty"`
+	LatestDate      *string      `json:"latest_date,omitempty"`
+	AllowedWindows  []TimeWindow `json:"allowed_windows,omitempty"`
+	AllowedWeekdays []string     `json:"allowed_weekdays,omitempty"`
+	ParentTaskID    *string      `json:"parent_task_id,omitempty"`
+}
+
+type Unplaced struct {
+	ID               string `json:"id"`
+	Title            string `json:"title"`
+	RemainingMinutes int    `json:"remaining_minutes"`
 }
 
 type ScheduleResult struct {
-	Updates []Update
-	Inserts []Insert
+	Updates              []Update              `json:"updates"`
+	Inserts              []Insert              `json:"inserts"`
+	Unplaced             []Unplaced            `json:"unplaced"`
+	Finishes             []Finish              `json:"finishes"`
+	DeadlineViolations   []DeadlineViolation   `json:"deadline_violations"`
+	ConstraintViolations []ConstraintViolation `json:"constraint_violations"`
 }
 
 const autoContMarker = "[auto-cont]"
 
+const HorizonDays = 365
+
 func AutoSchedule(tasks []Task, events []Event, settings Settings, focusKey string, allowReshuffle bool) ScheduleResult {
-	schedulable := make([]Task, 0)
-	for _, task := range tasks {
-		if task.EstimatedHours <= 0 || strings.EqualFold(task.Status, "completed") {
-			continue
-		}
-		if allowReshuffle || task.StartTime == nil || task.EndTime == nil {
-			schedulable = append(schedulable, task)
-		}
-	}
+	schedulable, schedulableIDs := collectSchedulable(tasks, allowReshuffle)
 	if len(schedulable) == 0 {
-		return ScheduleResult{}
-	}
-
-	busyByDate := map[string][][2]int{}
-	schedulableIDs := map[string]bool{}
-	for _, task := range schedulable {
-		schedulableIDs[task.ID] = true
-	}
-	for _, task := range tasks {
-		if task.StartTime == nil || task.EndTime == nil {
-			continue
+		finishes, violations := analyzeDeadlines(tasks, nil, nil, nil)
+		return ScheduleResult{
+			Finishes:             finishes,
+			DeadlineViolations:   violations,
+			ConstraintViolations: analyzeConstraints(tasks, nil, nil, settings),
 		}
-		if schedulableIDs[task.ID] {
-			continue
-		}
-		start := toMinutes(*task.StartTime)
-		end := toMinutes(*task.EndTime)
-		busyByDate[task.TaskDate] = append(busyByDate[task.TaskDate], [2]int{start, end})
-	}
-	for _, event := range events {
-		start := toMinutes(event.StartTime)
-		end := toMinutes(event.EndTime) + settings.BreakMinutes
-		busyByDate[event.EventDate] = append(busyByDate[event.EventDate], [2]int{start, end})
 	}
+	busyByDate := buildBusy(tasks, events, schedulableIDs, settings)
 
 	ordered := buildSchedulingQueue(schedulable)
 	updates := []Update{}
 	inserts := []Insert{}
+	segments := []segment{}
 
 	type entry struct {
 		task             Task
@@ -145,11 +162,15 @@ func AutoSchedule(tasks []Task, events []Event, settings Settings, focusKey stri
 	if useFocus {
 		focusBurst = 2
 	}
-	cursorDate := nextWeekday(tasks[0].TaskDate)
+	cursorDate := startCursor(tasks, settings)
+	horizonEnd := addDays(cursorDate, HorizonDays)
 
 	for len(focusQueue) > 0 || len(normalQueue) > 0 {
-		cursorDate = nextWeekday(cursorDate)
-		freeSlots := getFreeSlots(busyByDate[cursorDate], settings)
+		cursorDate = nextWorkDay(cursorDate, settings)
+		if cursorDate > horizonEnd {
+			break
+		}
+		freeSlots := getFreeSlots(dayBusy(busyByDate, cursorDate, settings), settings.workWindows(cursorDate))
 		if len(freeSlots) == 0 {
 			cursorDate = addDays(cursorDate, 1)
 			continue
@@ -157,78 +178,83 @@ func AutoSchedule(tasks []Task, events []Event, settings Settings, focusKey stri
 
 		for _, slot := range freeSlots {
 			slotCursor := slot[0]
-			for slotCursor < slot[1] && (len(focusQueue) > 0 || len(normalQueue) > 0) {
-				var current entry
-				if useFocus && len(focusQueue) > 0 && focusBurst > 0 {
-					current = focusQueue[0]
-					focusQueue = focusQueue[1:]
-					focusBurst--
-				} else if len(normalQueue) > 0 {
-					current = normalQueue[0]
-					normalQueue = normalQueue[1:]
-					if useFocus {
-						focusBurst = 2
+			for slotCursor < slot[1] {
+				deferred := []entry{}
+				for slotCursor < slot[1] && (len(focusQueue) > 0 || len(normalQueue) > 0) {
+					var current entry
+					if useFocus && len(focusQueue) > 0 && focusBurst > 0 {
+						current = focusQueue[0]
+						focusQueue = focusQueue[1:]
+						focusBurst--
+					} else if len(normalQueue) > 0 {
+						candidates := []Task{}
+						for _, queued := range normalQueue[:minInt(len(normalQueue), focusLookahead)] {
+							candidates = append(candidates, queued.task)
+						}
+						i := focusPick(candidates, settings.focusLevel(slotCursor))
+						current = normalQueue[i]
+						normalQueue = append(normalQueue[:i], normalQueue[i+1:]...)
+						if useFocus {
+							focusBurst = 2
+						}
+					} else if len(focusQueue) > 0 {
+						current = focusQueue[0]
+						focusQueue = focusQueue[1:]
+						if useFocus {
+							focusBurst = 1
+						}
+					} else {
+						break
 					}
-				} else if len(focusQueue) > 0 {
-					current = focusQueue[0]
-					focusQueue = focusQueue[1:]
-					if useFocus {
-						focusBurst = 1
+					start, end, ok := current.task.legalSpan(cursorDate, slotCursor, slot[1])
+					if !ok || start > slotCursor {
+						deferred = append(deferred, current)
+						continue
 					}
-				} else {
-					break
-				}
 
-				remainingInSlot := slot[1] - slotCursor
-				chunk := minInt(current.remainingMinutes, chunkMinutes, remainingInSlot)
-				slotEnd := slotCursor + chunk
-				if current.isFirstSegment {
-					updates = append(updates, Update{
-						ID:        current.task.ID,
-						TaskDate:  cursorDate,
-						StartTime: toTimeString(slotCursor),
-						EndTime:   toTimeString(slotEnd),
-					})
-					current.isFirstSegment = false
-				} else {
-					notes := autoContMarker
-					if current.task.Notes != nil {
-						notes = *current.task.Notes + "\n" + autoContMarker
+					chunk := minInt(current.remainingMinutes, chunkMinutes, end-slotCursor)
+					slotEnd := slotCursor + chunk
+					if current.isFirstSegment {
+						updates = append(updates, taskUpdate(current.task, cursorDate, slotCursor, slotEnd))
+						current.isFirstSegment = false
+					} else {
+						inserts = append(inserts, continuationInsert(current.task, cursorDate, slotCursor, slotEnd))
 					}
-					inserts = append(inserts, Insert{
-						UserID:         current.task.UserID,
-						Title:          current.task.Title + " (cont.)",
-						Company:        current.task.Company,
-						Project:        current.task.Project,
-						ProjectID:      current.task.ProjectID,
-						Notes:          &notes,
-						TaskDate:       cursorDate,
-						StartTime:      toTimeString(slotCursor),
-						EndTime:        toTimeString(slotEnd),
-						IsMilestone:    false,
-						EstimatedHours: float64(chunk) / 60,
-						Status:         "planned",
-						Dependencies:   []string{},
-						PriorityLevel:  current.task.PriorityLevel,
-						DeadlineType:   current.task.DeadlineType,
-						DeadlineDate:   current.task.DeadlineDate,
+					segments = append(segments, segment{
+						taskID: current.task.ID,
+						date:   cursorDate,
+						start:  slotCursor,
+						end:    slotEnd,
 					})
-				}
-				current.remainingMinutes -= chunk
-				slotCursor = slotEnd
-				if slotCursor+settings.BreakMinutes <= slot[1] {
-					slotCursor += settings.BreakMinutes
-				} else {
-					slotCursor = slot[1]
+					current.remainingMinutes -= chunk
+					slotCursor = slotEnd
+					if slotCursor+settings.BreakMinutes <= slot[1] {
+						slotCursor += settings.BreakMinutes
+					} else {
+						slotCursor = slot[1]
+					}
+
+					if current.remainingMinutes > 0 {
+						if useFocus && getProjectKey(current.task) == focusKey {
+							focusQueue = append(focusQueue, current)
+						} else {
+							normalQueue = append(normalQueue, current)
+						}
+					}
 				}
 
-				if current.remainingMinutes > 0 {
-					if useFocus && getProjectKey(current.task) == focusKey {
-						focusQueue = append(focusQueue, current)
+				next := slot[1]
+				for i := len(deferred) - 1; i >= 0; i-- {
+					if start, _, ok := deferred[i].task.legalSpan(cursorDate, slotCursor, slot[1]); ok && start < next {
+						next = start
+					}
+					if useFocus && getProjectKey(deferred[i].task) == focusKey {
+						focusQueue = append([]entry{deferred[i]}, focusQueue...)
 					} else {
-						normalQueue = append(normalQueue, current)
+						normalQueue = append([]entry{deferred[i]}, normalQueue...)
 					}
 				}
+				slotCursor = next
 			}
 		}
 
@@ -237,33 +263,266 @@ func AutoSchedule(tasks []Task, events []Event, settings Settings, focusKey stri
 		}
 	}
 
-	return ScheduleResult{Updates: updates, Inserts: inserts}
+	unplaced := []Unplaced{}
+	for _, current := range append(focusQueue, normalQueue...) {
+		unplaced = append(unplaced, Unplaced{
+			ID:               current.task.ID,
+			Title:            current.task.Title,
+			RemainingMinutes: current.remainingMinutes,
+		})
+	}
+
+	finishes, violations := analyzeDeadlines(tasks, schedulableIDs, segments, unplaced)
+
+	return ScheduleResult{
+		Updates:              updates,
+		Inserts:              inserts,
+		Unplaced:             unplaced,
+		Finishes:             finishes,
+		DeadlineViolations:   violations,
+		ConstraintViolations: analyzeConstraints(tasks, schedulableIDs, unplaced, settings),
+	}
 }
 
-func getFreeSlots(busy [][2]int, settings Settings) [][2]int {
-	if len(busy) == 0 {
-		return [][2]int{{settings.WorkStartMinutes, settings.WorkEndMinutes}}
+func collectSchedulable(tasks []Task, allowReshuffle bool) ([]Task, map[string]bool) {
+	schedulable := make([]Task, 0)
+	schedulableIDs := map[string]bool{}
+	for _, task := range tasks {
+		if task.EstimatedHours <= 0 || strings.EqualFold(task.Status, "completed") {
+			continue
+		}
+		if allowReshuffle || task.StartTime == nil || task.EndTime == nil {
+			schedulable = append(schedulable, task)
+			schedulableIDs[task.ID] = true
+		}
+	}
+	return schedulable, schedulableIDs
+}
+
+func buildBusy(tasks []Task, events []Event, schedulableIDs map[string]bool, settings Settings) map[string][][2]int {
+	busyByDate := map[string][][2]int{}
+	for _, task := range tasks {
+		if task.StartTime == nil || task.EndTime == nil {
+			continue
+		}
+		if schedulableIDs[task.ID] {
+			continue
+		}
+		start := toMinutes(*task.StartTime)
+		end := toMinutes(*task.EndTime)
+		busyByDate[task.TaskDate] = append(busyByDate[task.TaskDate], [2]int{start, end})
 	}
+	for _, event := range events {
+		if event.AllDay {
+			if !settings.allDayBlocks(event) {
+				continue
+			}
+			endDate := event.EventDate
+			if event.EndDate != nil && *event.EndDate > endDate {
+				endDate = *event.EndDate
+			}
+			for date := event.EventDate; date <= endDate; date = addDays(date, 1) {
+				busyByDate[date] = append(busyByDate[date], [2]int{0, 24 * 60})
+			}
+			continue
+		}
+		for date, interval := range eventIntervals(event, settings.BreakMinutes) {
+			busyByDate[date] = append(busyByDate[date], interval)
+		}
+	}
+	return busyByDate
+}
+
+const (
+	AllDayBlock         = "block"
+	AllDayInformational = "informational"
+)
+
+func (s Settings) allDayBlocks(event Event) bool {
+	policy := s.AllDayPolicy
+	if event.CalendarID != nil {
+		if calendarPolicy, ok := s.CalendarPolicies[*event.CalendarID]; ok {
+			policy = calendarPolicy
+		}
+	}
+	return policy == AllDayBlock
+}
+
+func eventIntervals(event Event, breakMinutes int) map[string][2]int {
+	start := toMinutes(event.StartTime)
+	end := toMinutes(event.EndTime)
+	endDate := event.EventDate
+	if event.EndDate != nil && *event.EndDate > event.EventDate {
+		endDate = *event.EndDate
+	} else if end < start {
+		endDate = addDays(event.EventDate, 1)
+	}
+	if endDate == event.EventDate {
+		return map[string][2]int{event.EventDate: {start, end + breakMinutes}}
+	}
+	intervals := map[string][2]int{event.EventDate: {start, 24 * 60}}
+	for date := addDays(event.EventDate, 1); date < endDate; date = addDays(date, 1) {
+		intervals[date] = [2]int{0, 24 * 60}
+	}
+	if end > 0 {
+		intervals[endDate] = [2]int{0, end + breakMinutes}
+	}
+	return intervals
+}
+
+func dayBusy(busyByDate map[string][][2]int, date string, settings Settings) [][2]int {
+	busy := busyByDate[date]
+	if date == settings.StartDate && settings.StartMinutes > 0 {
+		busy = append([][2]int{{0, settings.StartMinutes}}, busy...)
+	}
+	if gap, ok := dstGap(date, settings.Location); ok {
+		busy = append([][2]int{gap}, busy...)
+	}
+	return busy
+}
+
+func startCursor(tasks []Task, settings Settings) string {
+	cursorDate := nextWorkDay(tasks[0].TaskDate, settings)
+	if settings.StartDate != "" && cursorDate < settings.StartDate {
+		cursorDate = settings.StartDate
+	}
+	return cursorDate
+}
+
+func taskUpdate(task Task, date string, start, end int) Update {
+	return Update{
+		ID:            task.ID,
+		Title:         task.Title,
+		TaskDate:      date,
+		StartTime:     toTimeString(start),
+		EndTime:       toTimeString(end),
+		PrevTaskDate:  task.TaskDate,
+		PrevStartTime: task.StartTime,
+		PrevEndTime:   task.EndTime,
+	}
+}
+
+func IsContinuation(task Task) bool {
+	return task.Notes != nil && strings.Contains(*task.Notes, autoContMarker)
+}
+
+// IsContinuationOf reports whether task is a continuation row the scheduler
+// split off parent.
+func IsContinuationOf(task, parent Task) bool {
+	return task.ID != parent.ID && IsContinuation(task) && task.ParentTaskID != nil && *task.ParentTaskID == parent.ID
+}
+
+func continuationInsert(task Task, date string, start, end int) Insert {
+	notes := autoContMarker
+	if task.Notes != nil {
+		notes = *task.Notes + "\n" + autoContMarker
+	}
+	// A continuation that is split again still belongs to the original task.
+	parentID := task.ID
+	if task.ParentTaskID != nil {
+		parentID = *task.ParentTaskID
+	}
+	return Insert{
+		UserID:          task.UserID,
+		Title:           task.Title + " (cont.)",
+		Company:         task.Company,
+		Project:         task.Project,
+		ProjectID:       task.ProjectID,
+		Notes:           &notes,
+		TaskDate:        date,
+		StartTime:       toTimeString(start),
+		EndTime:         toTimeString(end),
+		IsMilestone:     false,
+		EstimatedHours:  float64(end-start) / 60,
+		Status:          "planned",
+		Dependencies:    []string{},
+		PriorityLevel:   task.PriorityLevel,
+		DeadlineType:    task.DeadlineType,
+		DeadlineDate:    task.DeadlineDate,
+		EarliestDate:    task.EarliestDate,
+		EarliestTime:    task.EarliestTime,
+		LatestDate:      task.LatestDate,
+		AllowedWindows:  task.AllowedWindows,
+		AllowedWeekdays: task.AllowedWeekdays,
+		ParentTaskID:    &parentID,
+	}
+}
+
+func ReleasePastDue(tasks []Task, today string, nowMinutes int) []Task {
+	out := append([]Task{}, tasks...)
+	for i, task := range out {
+		if strings.EqualFold(task.Status, "completed") {
+			continue
+		}
+		pastDue := task.TaskDate < today
+		if task.TaskDate == today && task.EndTime != nil && toMinutes(*task.EndTime) <= nowMinutes {
+			pastDue = true
+		}
+		if !pastDue {
+			continue
+		}
+		out[i].TaskDate = today
+		out[i].StartTime = nil
+		out[i].EndTime = nil
+	}
+	return out
+}
+
+func ReleaseRestOfDay(tasks []Task, today string, nowMinutes int) []Task {
+	out := append([]Task{}, tasks...)
+	for i, task := range out {
+		if task.TaskDate != today || task.StartTime == nil || task.EndTime == nil || strings.EqualFold(task.Status, "completed") {
+			continue
+		}
+		if toMinutes(*task.StartTime) < nowMinutes {
+			continue
+		}
+		out[i].StartTime = nil
+		out[i].EndTime = nil
+	}
+	return out
+}
+
+func getFreeSlots(busy [][2]int, windows [][2]int) [][2]int {
 	sort.Slice(busy, func(i, j int) bool {
 		return busy[i][0] < busy[j][0]
 	})
 	slots := [][2]int{}
-	cursor := settings.WorkStartMinutes
-	for _, interval := range busy {
-		if interval[0] > cursor {
-			slots = append(slots, [2]int{cursor, minInt(interval[0], settings.WorkEndMinutes)})
+	for _, window := range windows {
+		cursor := window[0]
+		for _, interval := range busy {
+			if interval[0] >= window[1] {
+				break
+			}
+			if interval[0] > cursor {
+				slots = append(slots, [2]int{cursor, interval[0]})
+			}
+			cursor = maxInt(cursor, interval[1])
+			if cursor >= window[1] {
+				break
+			}
 		}
-		cursor = maxInt(cursor, interval[1])
-		if cursor >= settings.WorkEndMinutes {
-			break
+		if cursor < window[1] {
+			slots = append(slots, [2]int{cursor, window[1]})
 		}
 	}
-	if cursor < settings.WorkEndMinutes {
-		slots = append(slots, [2]int{cursor, settings.WorkEndMinutes})
-	}
 	return slots
 }
 
+func (s Settings) workWindows(dateString string) [][2]int {
+	if s.DaysOff[dateString] {
+		return nil
+	}
+	date, _ := time.Parse("2006-01-02", dateString)
+	if s.Availability == nil {
+		if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			return nil
+		}
+		return [][2]int{{s.WorkStartMinutes, s.WorkEndMinutes}}
+	}
+	return s.Availability[date.Weekday()]
+}
+
 func ToMinutes(timeValue string) int {
 	parts := strings.Split(timeValue, ":")
 	if len(parts) < 2 {
@@ -322,12 +581,42 @@ func addDays(dateString string, days int) string {
 	return date.Format("2006-01-02")
 }
 
-func nextWeekday(dateString string) string {
-	date, _ := time.Parse("2006-01-02", dateString)
-	for date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
-		date = date.AddDate(0, 0, 1)
+func dstGap(dateString string, loc *time.Location) ([2]int, bool) {
+	if loc == nil {
+		return [2]int{}, false
 	}
-	return date.Format("2006-01-02")
+	date, err := time.Parse("2006-01-02", dateString)
+	if err != nil {
+		return [2]int{}, false
+	}
+	gap := [2]int{-1, -1}
+	for minutes := 0; minutes < 24*60; minutes += 15 {
+		wall := time.Date(date.Year(), date.Month(), date.Day(), minutes/60, minutes%60, 0, 0, loc)
+		skipped := wall.Hour()*60+wall.Minute() != minutes || wall.Day() != date.Day()
+		if skipped && gap[0] < 0 {
+			gap[0] = minutes
+		}
+		if !skipped && gap[0] >= 0 && gap[1] < 0 {
+			gap[1] = minutes
+		}
+	}
+	if gap[0] < 0 {
+		return [2]int{}, false
+	}
+	if gap[1] < 0 {
+		gap[1] = 24 * 60
+	}
+	return gap, true
+}
+
+func nextWorkDay(dateString string, settings Settings) string {
+	for i := 0; i < 7; i++ {
+		if len(settings.workWindows(dateString)) > 0 {
+			return dateString
+		}
+		dateString = addDays(dateString, 1)
+	}
+	return dateString
 }
 
 func getProjectKey(task Task) string {
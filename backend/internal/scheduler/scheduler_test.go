@@ -0,0 +1,25 @@
+package scheduler
+
+import "testing"
+
+func TestContinuationsBelongToTheTaskTheyWereSplitFrom(t *testing.T) {
+	first := Task{ID: "a", Title: "Weekly report", Project: "Ops"}
+	second := Task{ID: "b", Title: "Weekly report", Project: "Ops"}
+
+	insert := continuationInsert(first, "2026-10-20", 540, 600)
+	if insert.ParentTaskID == nil || *insert.ParentTaskID != "a" {
+		t.Fatalf("got parent %v, want a", insert.ParentTaskID)
+	}
+	continuation := Task{ID: "c", Title: insert.Title, Project: insert.Project, Notes: insert.Notes, ParentTaskID: insert.ParentTaskID}
+	if !IsContinuationOf(continuation, first) {
+		t.Errorf("continuation of a not matched to a")
+	}
+	if IsContinuationOf(continuation, second) {
+		t.Errorf("continuation of a matched to b, which shares its title")
+	}
+
+	again := continuationInsert(continuation, "2026-10-21", 540, 600)
+	if again.ParentTaskID == nil || *again.ParentTaskID != "a" {
+		t.Errorf("splitting a continuation again: got parent %v, want a", again.ParentTaskID)
+	}
+}
@@ -0,0 +1,366 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	SolverGreedy   = "greedy"
+	SolverDeadline = "deadline"
+)
+
+const (
+	maxSimulations     = 2000
+	hardDeadlineWeight = 1000
+	noDeadline         = "9999-12-31"
+)
+
+func Schedule(solver string, tasks []Task, events []Event, settings Settings, focusKey string, allowReshuffle bool) ScheduleResult {
+	if solver == SolverDeadline {
+		return DeadlineSchedule(tasks, events, settings, focusKey, allowReshuffle)
+	}
+	return AutoSchedule(tasks, events, settings, focusKey, allowReshuffle)
+}
+
+type placement struct {
+	segments  []segment
+	remaining map[string]int
+}
+
+func DeadlineSchedule(tasks []Task, events []Event, settings Settings, focusKey string, allowReshuffle bool) ScheduleResult {
+	schedulable, schedulableIDs := collectSchedulable(tasks, allowReshuffle)
+	if len(schedulable) == 0 {
+		finishes, violations := analyzeDeadlines(tasks, nil, nil, nil)
+		return ScheduleResult{
+			Finishes:             finishes,
+			DeadlineViolations:   violations,
+			ConstraintViolations: analyzeConstraints(tasks, nil, nil, settings),
+		}
+	}
+	busyByDate := buildBusy(tasks, events, schedulableIDs, settings)
+	startDate := startCursor(tasks, settings)
+	chunkMinutes := 90
+	if focusKey != "" {
+		chunkMinutes = 120
+	}
+
+	order := edfOrder(schedulable, focusKey)
+	best := simulate(order, busyByDate, settings, startDate, chunkMinutes)
+	bestCost := placementCost(order, best)
+	simulations := 1
+	for bestCost > 0 && simulations < maxSimulations {
+		improved := false
+		for _, i := range lateIndexes(order, best) {
+			for k := i - 1; k >= 0 && simulations < maxSimulations; k-- {
+				if dependsOn(order[i], order[k]) {
+					break
+				}
+				candidate := moveTask(order, i, k)
+				candidatePlacement := simulate(candidate, busyByDate, settings, startDate, chunkMinutes)
+				simulations++
+				if cost := placementCost(candidate, candidatePlacement); cost < bestCost {
+					order = candidate
+					best = candidatePlacement
+					bestCost = cost
+					improved = true
+					break
+				}
+			}
+			if improved {
+				break
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+
+	tasksByID := map[string]Task{}
+	for _, task := range order {
+		tasksByID[task.ID] = task
+	}
+	updates := []Update{}
+	inserts := []Insert{}
+	placed := map[string]bool{}
+	for _, seg := range best.segments {
+		task := tasksByID[seg.taskID]
+		if !placed[seg.taskID] {
+			updates = append(updates, taskUpdate(task, seg.date, seg.start, seg.end))
+			placed[seg.taskID] = true
+		} else {
+			inserts = append(inserts, continuationInsert(task, seg.date, seg.start, seg.end))
+		}
+	}
+	unplaced := []Unplaced{}
+	for _, task := range order {
+		if remaining := best.remaining[task.ID]; remaining > 0 {
+			unplaced = append(unplaced, Unplaced{ID: task.ID, Title: task.Title, RemainingMinutes: remaining})
+		}
+	}
+	finishes, violations := analyzeDeadlines(tasks, schedulableIDs, best.segments, unplaced)
+	return ScheduleResult{
+		Updates:              updates,
+		Inserts:              inserts,
+		Unplaced:             unplaced,
+		Finishes:             finishes,
+		DeadlineViolations:   violations,
+		ConstraintViolations: analyzeConstraints(tasks, schedulableIDs, unplaced, settings),
+	}
+}
+
+func simulate(order []Task, busyByDate map[string][][2]int, settings Settings, startDate string, chunkMinutes int) placement {
+	result := placement{remaining: map[string]int{}}
+	horizonEnd := addDays(startDate, HorizonDays)
+	first := nextWorkDay(startDate, settings)
+	free := map[string][][2]int{}
+	inOrder := map[string]bool{}
+	for _, task := range order {
+		inOrder[task.ID] = true
+	}
+	// finished holds where each fully placed task's last segment ends, so a
+	// dependent task never starts before the work it waits on, even when
+	// that work was pushed past free time the dependent could have used.
+	finished := map[string]segment{}
+	for _, task := range order {
+		remaining := int(math.Ceil(task.EstimatedHours * 60))
+		after, ready := dependencyBound(task, inOrder, finished)
+		if !ready {
+			result.remaining[task.ID] = remaining
+			continue
+		}
+		date := first
+		if task.EarliestDate != nil && *task.EarliestDate > date {
+			date = nextWorkDay(*task.EarliestDate, settings)
+		}
+		if after.date > date {
+			date = nextWorkDay(after.date, settings)
+		}
+		last := segment{}
+		for remaining > 0 && date <= horizonEnd {
+			slots, ok := free[date]
+			if !ok {
+				slots = getFreeSlots(dayBusy(busyByDate, date, settings), settings.workWindows(date))
+				free[date] = slots
+			}
+			placed := false
+			for i, slot := range slots {
+				from := slot[0]
+				if date == after.date {
+					from = maxInt(from, after.end)
+				}
+				start, end, ok := task.legalSpan(date, from, slot[1])
+				if !ok {
+					continue
+				}
+				chunk := minInt(remaining, chunkMinutes, end-start)
+				placedSegment := segment{
+					taskID: task.ID,
+					date:   date,
+					start:  start,
+					end:    start + chunk,
+				}
+				result.segments = append(result.segments, placedSegment)
+				if placedSegment.date > last.date || (placedSegment.date == last.date && placedSegment.end > last.end) {
+					last = placedSegment
+				}
+				remaining -= chunk
+				next := start + chunk
+				if next+settings.BreakMinutes <= slot[1] {
+					next += settings.BreakMinutes
+				} else {
+					next = slot[1]
+				}
+				rest := append([][2]int{}, slots[:i]...)
+				if before := start - settings.BreakMinutes; before > slot[0] {
+					rest = append(rest, [2]int{slot[0], before})
+				}
+				if next < slot[1] {
+					rest = append(rest, [2]int{next, slot[1]})
+				}
+				free[date] = append(rest, slots[i+1:]...)
+				placed = true
+				break
+			}
+			if !placed {
+				next := nextWorkDay(addDays(date, 1), settings)
+				if date == first && len(slots) == 0 {
+					first = next
+				}
+				date = next
+			}
+		}
+		if remaining > 0 {
+			result.remaining[task.ID] = remaining
+		} else if last.date != "" {
+			finished[task.ID] = last
+		}
+	}
+	return result
+}
+
+// dependencyBound returns the latest end among the task's dependencies that
+// are being placed in this run. ready is false while one of them is still
+// unplaced, because the task cannot start before it finishes.
+func dependencyBound(task Task, inOrder map[string]bool, finished map[string]segment) (segment, bool) {
+	bound := segment{}
+	for _, depID := range task.Dependencies {
+		if !inOrder[depID] {
+			continue
+		}
+		end, ok := finished[depID]
+		if !ok {
+			return segment{}, false
+		}
+		if end.date > bound.date || (end.date == bound.date && end.end > bound.end) {
+			bound = end
+		}
+	}
+	return bound, true
+}
+
+func placementCost(order []Task, p placement) int {
+	lateness := lateMinutes(order, p)
+	cost := 0
+	for _, task := range order {
+		if isHard(task) {
+			cost += lateness[task.ID] * hardDeadlineWeight
+		} else {
+			cost += lateness[task.ID]
+		}
+	}
+	return cost
+}
+
+func lateMinutes(order []Task, p placement) map[string]int {
+	deadlines := map[string]string{}
+	for _, task := range order {
+		if task.DeadlineDate != nil && *task.DeadlineDate != "" {
+			deadlines[task.ID] = *task.DeadlineDate
+		}
+	}
+	lateness := map[string]int{}
+	for _, seg := range p.segments {
+		deadline, ok := deadlines[seg.taskID]
+		if !ok || seg.date <= deadline {
+			continue
+		}
+		lateness[seg.taskID] += (seg.end - seg.start) * daysBetween(deadline, seg.date)
+	}
+	for id, remaining := range p.remaining {
+		if _, ok := deadlines[id]; ok {
+			lateness[id] += remaining * HorizonDays
+		}
+	}
+	return lateness
+}
+
+func lateIndexes(order []Task, p placement) []int {
+	lateness := lateMinutes(order, p)
+	indexes := []int{}
+	for i, task := range order {
+		if lateness[task.ID] > 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return isHard(order[indexes[a]]) && !isHard(order[indexes[b]])
+	})
+	return indexes
+}
+
+func moveTask(order []Task, from, to int) []Task {
+	out := make([]Task, 0, len(order))
+	out = append(out, order[:to]...)
+	out = append(out, order[from])
+	out = append(out, order[to:from]...)
+	out = append(out, order[from+1:]...)
+	return out
+}
+
+func dependsOn(task, other Task) bool {
+	for _, depID := range task.Dependencies {
+		if depID == other.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func edfOrder(tasks []Task, focusKey string) []Task {
+	effective := map[string]string{}
+	for _, task := range tasks {
+		effective[task.ID] = noDeadline
+		if task.DeadlineDate != nil && *task.DeadlineDate != "" {
+			effective[task.ID] = *task.DeadlineDate
+		}
+	}
+	for range tasks {
+		changed := false
+		for _, task := range tasks {
+			for _, depID := range task.Dependencies {
+				if current, ok := effective[depID]; ok && effective[task.ID] < current {
+					effective[depID] = effective[task.ID]
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	sorted := append([]Task{}, tasks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if effective[a.ID] != effective[b.ID] {
+			return effective[a.ID] < effective[b.ID]
+		}
+		if isHard(a) != isHard(b) {
+			return isHard(a)
+		}
+		if focusKey != "" && (getProjectKey(a) == focusKey) != (getProjectKey(b) == focusKey) {
+			return getProjectKey(a) == focusKey
+		}
+		if a.PriorityLevel != b.PriorityLevel {
+			return a.PriorityLevel < b.PriorityLevel
+		}
+		return a.EstimatedHours < b.EstimatedHours
+	})
+
+	tasksByID := map[string]Task{}
+	for _, task := range sorted {
+		tasksByID[task.ID] = task
+	}
+	queue := []Task{}
+	scheduled := map[string]bool{}
+	remaining := sorted
+	for len(remaining) > 0 {
+		next := -1
+		for i, task := range remaining {
+			if dependenciesMet(task, scheduled, tasksByID) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			queue = append(queue, remaining...)
+			break
+		}
+		queue = append(queue, remaining[next])
+		scheduled[remaining[next].ID] = true
+		remaining = append(append([]Task{}, remaining[:next]...), remaining[next+1:]...)
+	}
+	return queue
+}
+
+func isHard(task Task) bool {
+	return task.DeadlineType != nil && *task.DeadlineType == "hard"
+}
+
+func daysBetween(from, to string) int {
+	fromDate, _ := time.Parse("2006-01-02", from)
+	toDate, _ := time.Parse("2006-01-02", to)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func strPtr(value string) *string {
+	return &value
+}
+
+func TestDeadlineScheduleKeepsDependentsAfterTheirDependencies(t *testing.T) {
+	settings := Settings{
+		WorkStartMinutes: 540,
+		WorkEndMinutes:   1020,
+		BreakMinutes:     15,
+		Location:         time.UTC,
+		StartDate:        "2026-10-19",
+	}
+	tests := []struct {
+		name      string
+		blocker   Task
+		wantAfter bool
+	}{
+		{
+			name:      "allowed window",
+			blocker:   Task{AllowedWindows: []TimeWindow{{Start: "15:00", End: "17:00"}}},
+			wantAfter: true,
+		},
+		{
+			name:      "earliest date",
+			blocker:   Task{EarliestDate: strPtr("2026-10-21")},
+			wantAfter: true,
+		},
+		{
+			name:    "dependency never placed",
+			blocker: Task{AllowedWeekdays: []string{"sat"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blocker := test.blocker
+			blocker.ID, blocker.Title, blocker.TaskDate, blocker.EstimatedHours = "a", "Blocker", "2026-10-19", 1
+			dependent := Task{ID: "b", Title: "Dependent", TaskDate: "2026-10-19", EstimatedHours: 1, Dependencies: []string{"a"}}
+
+			result := DeadlineSchedule([]Task{blocker, dependent}, nil, settings, "", false)
+			placed := map[string]Update{}
+			for _, update := range result.Updates {
+				placed[update.ID] = update
+			}
+			a, aOK := placed["a"]
+			b, bOK := placed["b"]
+			if !test.wantAfter {
+				if aOK || bOK {
+					t.Fatalf("got updates %+v, want neither task placed", result.Updates)
+				}
+				if len(result.Unplaced) != 2 {
+					t.Fatalf("got unplaced %+v, want both tasks", result.Unplaced)
+				}
+				return
+			}
+			if !aOK || !bOK {
+				t.Fatalf("got updates %+v, want both tasks placed", result.Updates)
+			}
+			if b.TaskDate < a.TaskDate || (b.TaskDate == a.TaskDate && b.StartTime < a.EndTime) {
+				t.Errorf("dependent placed %s %s, before its dependency ends %s %s", b.TaskDate, b.StartTime, a.TaskDate, a.EndTime)
+			}
+		})
+	}
+}
@@ -72,6 +72,16 @@ func (c *Client) Upsert(table string, payload any) ([]byte, error) {
 	return c.do("POST", endpoint, payload, true, true, "resolution=merge-duplicates")
 }
 
+func (c *Client) UpsertOn(table, onConflict string, payload any) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/rest/v1/%s?on_conflict=%s", c.BaseURL, table, url.QueryEscape(onConflict))
+	return c.do("POST", endpoint, payload, true, true, "resolution=merge-duplicates")
+}
+
+func (c *Client) InsertIgnoreOn(table, onConflict string, payload any) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/rest/v1/%s?on_conflict=%s", c.BaseURL, table, url.QueryEscape(onConflict))
+	return c.do("POST", endpoint, payload, true, true, "resolution=ignore-duplicates")
+}
+
 func (c *Client) Update(table, filter string, payload any) ([]byte, error) {
 	endpoint := fmt.Sprintf("%s/rest/v1/%s?%s", c.BaseURL, table, filter)
 	return c.do("PATCH", endpoint, payload, true, true)
@@ -83,6 +93,11 @@ func (c *Client) Delete(table, filter string) error {
 	return err
 }
 
+func (c *Client) DeleteReturning(table, filter string) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/rest/v1/%s?%s", c.BaseURL, table, filter)
+	return c.do("DELETE", endpoint, nil, true, true)
+}
+
 func (c *Client) do(method, endpoint string, payload any, useServiceKey bool, returnRepresentation bool, prefer ...string) ([]byte, error) {
 	var body io.Reader
 	if payload != nil {